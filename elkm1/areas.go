@@ -0,0 +1,249 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+	"gopkg.in/yaml.v3"
+)
+
+// entryExitTimers records the most recent EE message received for each
+// area and the time at which it was received by the connection's reader.
+type entryExitTimers struct {
+	mu     sync.Mutex
+	timers map[int]entryExitTimer
+}
+
+type entryExitTimer struct {
+	protocol.EntryExitTimer
+	started time.Time
+}
+
+func newEntryExitTimers() *entryExitTimers {
+	return &entryExitTimers{timers: map[int]entryExitTimer{}}
+}
+
+func (et *entryExitTimers) handle(_ context.Context, f protocol.Frame) {
+	var ee protocol.EntryExitTimer
+	if err := ee.Decode(f.Data); err != nil {
+		return
+	}
+	et.record(ee, time.Now())
+}
+
+func (et *entryExitTimers) record(ee protocol.EntryExitTimer, now time.Time) {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	if ee.Timer1 == 0 && ee.Timer2 == 0 {
+		delete(et.timers, ee.Area)
+		return
+	}
+	et.timers[ee.Area] = entryExitTimer{EntryExitTimer: ee, started: now}
+}
+
+// ended discards the area's timer for the specified delay once the
+// arming status shows that the delay is no longer running.
+func (et *entryExitTimers) ended(area int, delay protocol.EntryExitDelay) {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	if t, ok := et.timers[area]; ok && t.Delay == delay {
+		delete(et.timers, area)
+	}
+}
+
+// remaining returns the number of seconds remaining until the first of
+// the area's entry or exit timers that is still running expires, or
+// false if no such timer is known to be running.
+func (et *entryExitTimers) remaining(area int, delay protocol.EntryExitDelay, now time.Time) (int, bool) {
+	et.mu.Lock()
+	t, ok := et.timers[area]
+	et.mu.Unlock()
+	if !ok || t.Delay != delay {
+		return 0, false
+	}
+	elapsed := int(now.Sub(t.started) / time.Second)
+	secs, running := 0, false
+	for _, timer := range []int{t.Timer1, t.Timer2} {
+		if r := timer - elapsed; r > 0 && (!running || r < secs) {
+			secs, running = r, true
+		}
+	}
+	return secs, running
+}
+
+type AreaConfig struct {
+	AreaNumber int `yaml:"area"`
+}
+
+type Area struct {
+	m1DeviceBase
+	devices.DeviceBase[AreaConfig]
+}
+
+func NewArea(_ devices.Options) *Area {
+	return &Area{
+		m1DeviceBase: m1DeviceBase{},
+	}
+}
+
+func (a *Area) UnmarshalYAML(node *yaml.Node) error {
+	if err := node.Decode(&a.DeviceConfigCustom); err != nil {
+		return err
+	}
+	if an := a.DeviceConfigCustom.AreaNumber; an < 1 || an > protocol.NumAreas {
+		return fmt.Errorf("invalid area number: %v", an)
+	}
+	return nil
+}
+
+func (a *Area) Conditions() map[string]devices.Condition {
+	return map[string]devices.Condition{
+		"disarmed": a.condition(func(s protocol.AreaStatus) bool {
+			return s.Armed == protocol.Disarmed
+		}),
+		"armed": a.condition(func(s protocol.AreaStatus) bool {
+			return s.Armed != protocol.Disarmed
+		}),
+		"armed-away": a.condition(func(s protocol.AreaStatus) bool {
+			return s.Armed == protocol.ArmedAway
+		}),
+		"armed-stay": a.condition(func(s protocol.AreaStatus) bool {
+			return s.Armed == protocol.ArmedStay || s.Armed == protocol.ArmedStayInstant
+		}),
+		"armed-night": a.condition(func(s protocol.AreaStatus) bool {
+			return s.Armed == protocol.ArmedNight || s.Armed == protocol.ArmedNightInstant
+		}),
+		"armed-vacation": a.condition(func(s protocol.AreaStatus) bool {
+			return s.Armed == protocol.ArmedVacation
+		}),
+		"ready-to-arm": a.condition(func(s protocol.AreaStatus) bool {
+			return s.ArmUp == protocol.ReadyToArm
+		}),
+		"not-ready": a.condition(func(s protocol.AreaStatus) bool {
+			return s.ArmUp == protocol.NotReadyToArm
+		}),
+		"force-arm-ready": a.condition(func(s protocol.AreaStatus) bool {
+			return s.ArmUp == protocol.ReadyToForceArm
+		}),
+		"in-exit-delay": a.condition(func(s protocol.AreaStatus) bool {
+			return s.ArmUp == protocol.ArmedWithExitTimer
+		}),
+		"exit-delay-running":  a.delayRunning(protocol.ExitDelay),
+		"entry-delay-running": a.delayRunning(protocol.EntryDelay),
+		"alarm-active":        a.AlarmActive,
+	}
+}
+
+func (a *Area) ConditionsHelp() map[string]string {
+	return map[string]string{
+		"disarmed":            "true if the area is disarmed",
+		"armed":               "true if the area is armed in any mode",
+		"armed-away":          "true if the area is armed away",
+		"armed-stay":          "true if the area is armed stay or stay instant",
+		"armed-night":         "true if the area is armed night or night instant",
+		"armed-vacation":      "true if the area is armed vacation",
+		"ready-to-arm":        "true if the area is ready to arm",
+		"not-ready":           "true if the area is not ready to arm",
+		"force-arm-ready":     "true if the area is ready to arm, but only by force arming a violated zone",
+		"in-exit-delay":       "true if the area is armed and its exit timer is running",
+		"exit-delay-running":  "true if the area's exit delay is running, the number of seconds remaining is returned",
+		"entry-delay-running": "true if the area's entry delay is running, the number of seconds remaining is returned",
+		"alarm-active":        "true if the area is in full alarm, the alarm type is returned",
+	}
+}
+
+// armingStatus returns the area number, either the configured one or
+// that specified as an argument, and the arming status of all areas.
+func (a *Area) armingStatus(ctx context.Context, opts devices.OperationArgs) (int, protocol.ArmingStatusReply, error) {
+	ctx, sess, err := a.m1.session(ctx)
+	if err != nil {
+		return 0, protocol.ArmingStatusReply{}, err
+	}
+	defer sess.Release()
+	an := a.DeviceConfigCustom.AreaNumber
+	if len(opts.Args) > 0 {
+		if an, err = areaArg(opts.Args); err != nil {
+			return 0, protocol.ArmingStatusReply{}, err
+		}
+	}
+	status, err := protocol.GetArmingStatus(ctx, sess)
+	return an, status, err
+}
+
+func (a *Area) status(ctx context.Context, opts devices.OperationArgs) (protocol.AreaStatus, error) {
+	an, status, err := a.armingStatus(ctx, opts)
+	if err != nil {
+		return protocol.AreaStatus{}, err
+	}
+	as := status.Areas[an-1]
+	if opts.Writer != nil {
+		_, _ = opts.Writer.Write(fmt.Appendf(nil, "area: %v, status %v, %v, %v", an, as.Armed, as.ArmUp, as.Alarm))
+	}
+	if a.logger != nil {
+		a.logger.Info("area-status", "area", an, "armed", as.Armed, "arm-up", as.ArmUp, "alarm", as.Alarm)
+	}
+	return as, nil
+}
+
+func (a *Area) condition(pred func(protocol.AreaStatus) bool) devices.Condition {
+	return func(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+		status, err := a.status(ctx, opts)
+		if err != nil {
+			return nil, false, err
+		}
+		return nil, pred(status), nil
+	}
+}
+
+func (a *Area) AlarmActive(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	status, err := a.status(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	if !status.Alarm.InAlarm() {
+		return nil, false, nil
+	}
+	return status.Alarm.String(), true, nil
+}
+
+// delayRunning returns a condition that is true if the area's entry or
+// exit delay is running according to its arming status. The number of
+// seconds remaining is taken from the most recent EE message for the area
+// if one has been received whilst the delay is running, and from the
+// arming status otherwise.
+func (a *Area) delayRunning(delay protocol.EntryExitDelay) devices.Condition {
+	return func(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+		an, status, err := a.armingStatus(ctx, opts)
+		if err != nil {
+			return nil, false, err
+		}
+		as := status.Areas[an-1]
+		running := as.ArmUp == protocol.ArmedWithExitTimer
+		if delay == protocol.EntryDelay {
+			running = as.Alarm == protocol.AreaEntranceDelayActive
+		}
+		if !running {
+			a.m1.entryExit.ended(an, delay)
+			return 0, false, nil
+		}
+		secs, ok := a.m1.entryExit.remaining(an, delay, time.Now())
+		if !ok {
+			secs = status.Timer
+		}
+		if opts.Writer != nil {
+			_, _ = opts.Writer.Write(fmt.Appendf(nil, "area: %v, %v delay running, %v seconds remaining", an, delay, secs))
+		}
+		if a.logger != nil {
+			a.logger.Info("area-delay", "area", an, "delay", delay.String(), "remaining", secs)
+		}
+		return secs, true, nil
+	}
+}
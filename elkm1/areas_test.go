@@ -0,0 +1,62 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestEntryExitRemaining(t *testing.T) {
+	et := newEntryExitTimers()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	et.record(protocol.EntryExitTimer{Area: 1, Delay: protocol.ExitDelay, Timer1: 60, Timer2: 120}, start)
+	et.record(protocol.EntryExitTimer{Area: 2, Delay: protocol.EntryDelay, Timer1: 0, Timer2: 30}, start)
+
+	for i, tc := range []struct {
+		area    int
+		delay   protocol.EntryExitDelay
+		elapsed time.Duration
+		secs    int
+		running bool
+	}{
+		{1, protocol.ExitDelay, 0, 60, true},
+		{1, protocol.ExitDelay, 10500 * time.Millisecond, 50, true},
+		{1, protocol.ExitDelay, 60 * time.Second, 60, true},
+		{1, protocol.ExitDelay, 119 * time.Second, 1, true},
+		{1, protocol.ExitDelay, 120 * time.Second, 0, false},
+		{1, protocol.EntryDelay, 0, 0, false},
+		{2, protocol.EntryDelay, 0, 30, true},
+		{2, protocol.EntryDelay, 31 * time.Second, 0, false},
+		{3, protocol.ExitDelay, 0, 0, false},
+	} {
+		secs, running := et.remaining(tc.area, tc.delay, start.Add(tc.elapsed))
+		if got, want := secs, tc.secs; got != want {
+			t.Errorf("%v: got %v, want %v", i, got, want)
+		}
+		if got, want := running, tc.running; got != want {
+			t.Errorf("%v: got %v, want %v", i, got, want)
+		}
+	}
+
+	// A delay that has ended according to the arming status is discarded,
+	// but only for that delay.
+	et.ended(1, protocol.EntryDelay)
+	if _, running := et.remaining(1, protocol.ExitDelay, start); !running {
+		t.Errorf("exit delay should still be running")
+	}
+	et.ended(1, protocol.ExitDelay)
+	if _, running := et.remaining(1, protocol.ExitDelay, start); running {
+		t.Errorf("exit delay should have ended")
+	}
+
+	// Timers of zero carry no delay.
+	et.record(protocol.EntryExitTimer{Area: 2, Delay: protocol.EntryDelay}, start)
+	if _, running := et.remaining(2, protocol.EntryDelay, start); running {
+		t.Errorf("entry delay should have ended")
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloudeng.io/logging/ctxlog"
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+type SetTimeInfo struct {
+	Previous time.Time     `json:"previous"`
+	Time     time.Time     `json:"time"`
+	Drift    time.Duration `json:"drift"`
+	DST      bool          `json:"dst"`
+	M1DST    bool          `json:"m1_dst"`
+}
+
+// parseSetTimeArgs parses the optional time zone and dst|std arguments
+// to the settime operation. The time zone defaults to the host's and the
+// use of daylight saving time to whether it is in effect in that time zone.
+func parseSetTimeArgs(now time.Time, args []string) (*time.Location, bool, error) {
+	loc := time.Local
+	if len(args) > 0 && args[0] != "dst" && args[0] != "std" {
+		if args[0] != "local" {
+			l, err := time.LoadLocation(args[0])
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid time zone: %v: %w", args[0], err)
+			}
+			loc = l
+		}
+		args = args[1:]
+	}
+	dst := now.In(loc).IsDST()
+	if len(args) > 0 {
+		switch args[0] {
+		case "dst":
+			dst = true
+		case "std":
+			dst = false
+		default:
+			return nil, false, fmt.Errorf("invalid daylight saving time flag: %v, must be dst or std", args[0])
+		}
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		return nil, false, fmt.Errorf("unexpected arguments: %v", args)
+	}
+	return loc, dst, nil
+}
+
+// wallClock returns t in loc using either its standard or daylight
+// saving time offset, regardless of which is in effect at t. This allows
+// the M1 to be set to standard time when it is configured to not observe
+// daylight saving time.
+func wallClock(t time.Time, loc *time.Location, dst bool) time.Time {
+	t = t.In(loc)
+	if t.IsDST() == dst {
+		return t
+	}
+	offset, ok := zoneOffset(t.Year(), loc, dst)
+	if !ok {
+		return t
+	}
+	name, _ := t.Zone()
+	return t.In(time.FixedZone(name, offset))
+}
+
+// zoneOffset returns the offset used by loc during the specified year
+// for daylight saving time if dst is true and standard time otherwise.
+// The offset is found by looking for a time at which IsDST matches dst
+// rather than by comparing offsets since some time zones, eg.
+// Europe/Dublin, observe a negative daylight saving time in winter.
+func zoneOffset(year int, loc *time.Location, dst bool) (int, bool) {
+	for month := time.January; month <= time.December; month++ {
+		t := time.Date(year, month, 15, 12, 0, 0, 0, loc)
+		if t.IsDST() == dst {
+			_, offset := t.Zone()
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+// inLocation returns the wall clock time of t, as returned by the M1 in
+// the local time zone, in loc.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+}
+
+// clockDrift returns how far the M1's clock, as returned by GetTime, is
+// ahead of now, a negative drift means that it is behind. The M1's clock
+// only has a resolution of seconds.
+func clockDrift(m1Time, now time.Time) time.Duration {
+	return inLocation(m1Time, now.Location()).Sub(now).Round(time.Second)
+}
+
+func (m1 *M1xep) setTime(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	loc, dst, err := parseSetTimeArgs(time.Now(), args.Args)
+	if err != nil {
+		return nil, err
+	}
+	previous, m1dst, err := protocol.GetTime(ctx, sess)
+	if err != nil {
+		return nil, err
+	}
+	now := wallClock(time.Now(), loc, dst)
+	drift := clockDrift(previous, now)
+	if err := protocol.SetTime(ctx, sess, now); err != nil {
+		return nil, err
+	}
+	info := SetTimeInfo{
+		Previous: previous,
+		Time:     inLocation(now, time.Local),
+		Drift:    drift,
+		DST:      dst,
+		M1DST:    m1dst,
+	}
+	fmt.Fprintf(args.Writer, "settime: %v, was %v, drift %v\n", now.Format(time.DateTime), previous.Format(time.DateTime), drift)
+	if m1dst != dst {
+		fmt.Fprintf(args.Writer, "settime: warning: the M1's daylight saving time setting (%v) differs from that requested (%v)\n", m1dst, dst)
+	}
+	ctxlog.Info(ctx, "elk-m1: settime", "time", now, "previous", previous, "drift", drift, "dst", dst, "m1-dst", m1dst)
+	return info, nil
+}
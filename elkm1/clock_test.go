@@ -0,0 +1,143 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("%v: %v", name, err)
+	}
+	return loc
+}
+
+func TestParseSetTimeArgs(t *testing.T) {
+	la := loadLocation(t, "America/Los_Angeles")
+	winter := time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC)
+	summer := time.Date(2024, time.July, 15, 12, 0, 0, 0, time.UTC)
+	for i, tc := range []struct {
+		now  time.Time
+		args string
+		loc  *time.Location
+		dst  bool
+	}{
+		{winter, "", time.Local, winter.In(time.Local).IsDST()},
+		{summer, "local", time.Local, summer.In(time.Local).IsDST()},
+		{winter, "local dst", time.Local, true},
+		{summer, "std", time.Local, false},
+		{winter, "dst", time.Local, true},
+		{winter, "America/Los_Angeles", la, false},
+		{summer, "America/Los_Angeles", la, true},
+		{summer, "America/Los_Angeles std", la, false},
+		{winter, "America/Los_Angeles dst", la, true},
+	} {
+		loc, dst, err := parseSetTimeArgs(tc.now, strings.Fields(tc.args))
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", i, err)
+			continue
+		}
+		if got, want := loc.String(), tc.loc.String(); got != want {
+			t.Errorf("%v: got %v, want %v", i, got, want)
+		}
+		if got, want := dst, tc.dst; got != want {
+			t.Errorf("%v: got %v, want %v", i, got, want)
+		}
+	}
+
+	for _, args := range []string{"Nowhere/Special", "dst std", "local summer", "local dst extra"} {
+		if _, _, err := parseSetTimeArgs(winter, strings.Fields(args)); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+func TestWallClock(t *testing.T) {
+	la := loadLocation(t, "America/Los_Angeles")
+	sydney := loadLocation(t, "Australia/Sydney")
+	dublin := loadLocation(t, "Europe/Dublin")
+	winter := time.Date(2024, time.January, 15, 20, 0, 0, 0, time.UTC)
+	summer := time.Date(2024, time.July, 15, 20, 0, 0, 0, time.UTC)
+	for i, tc := range []struct {
+		t    time.Time
+		loc  *time.Location
+		dst  bool
+		want string
+	}{
+		// Northern Hemisphere, daylight saving time in summer.
+		{winter, la, false, "2024-01-15 12:00:00"},
+		{winter, la, true, "2024-01-15 13:00:00"},
+		{summer, la, true, "2024-07-15 13:00:00"},
+		{summer, la, false, "2024-07-15 12:00:00"},
+		// Southern Hemisphere, daylight saving time in January.
+		{winter, sydney, true, "2024-01-16 07:00:00"},
+		{winter, sydney, false, "2024-01-16 06:00:00"},
+		{summer, sydney, false, "2024-07-16 06:00:00"},
+		{summer, sydney, true, "2024-07-16 07:00:00"},
+		// Negative daylight saving time, GMT in winter is flagged as
+		// daylight saving time and IST in summer as standard time.
+		{winter, dublin, true, "2024-01-15 20:00:00"},
+		{winter, dublin, false, "2024-01-15 21:00:00"},
+		{summer, dublin, false, "2024-07-15 21:00:00"},
+		{summer, dublin, true, "2024-07-15 20:00:00"},
+		// No daylight saving time at all.
+		{summer, time.UTC, true, "2024-07-15 20:00:00"},
+	} {
+		wc := wallClock(tc.t, tc.loc, tc.dst)
+		if got, want := wc.Format(time.DateTime), tc.want; got != want {
+			t.Errorf("%v: %v: got %v, want %v", i, tc.loc, got, want)
+		}
+		if !wc.Equal(tc.t) && tc.t.In(tc.loc).IsDST() == tc.dst {
+			t.Errorf("%v: %v: time changed: %v", i, tc.loc, wc)
+		}
+	}
+}
+
+func TestInLocation(t *testing.T) {
+	la := loadLocation(t, "America/Los_Angeles")
+	m1 := time.Date(2024, time.July, 15, 13, 4, 5, 0, time.Local)
+	il := inLocation(m1, la)
+	if got, want := il.Format(time.DateTime), "2024-07-15 13:04:05"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := il.Location(), la; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestClockDrift(t *testing.T) {
+	la := loadLocation(t, "America/Los_Angeles")
+	now := time.Date(2024, time.July, 15, 13, 0, 0, 400*int(time.Millisecond), la)
+	for i, tc := range []struct {
+		m1    string
+		drift time.Duration
+	}{
+		{"2024-07-15 13:00:00", 0},
+		{"2024-07-15 13:00:10", 10 * time.Second},
+		{"2024-07-15 12:59:50", -10 * time.Second},
+		{"2024-07-15 13:00:01", time.Second},
+		{"2024-07-15 14:00:00", time.Hour},
+	} {
+		// The M1's time is returned in the local time zone.
+		m1, err := time.ParseInLocation(time.DateTime, tc.m1, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := clockDrift(m1, now), tc.drift; got != want {
+			t.Errorf("%v: got %v, want %v", i, got, want)
+		}
+	}
+	// Drift is rounded to the nearest second.
+	now = now.Add(200 * time.Millisecond)
+	m1 := time.Date(2024, time.July, 15, 13, 0, 0, 0, time.Local)
+	if got, want := clockDrift(m1, now), -time.Second; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+	"gopkg.in/yaml.v3"
+)
+
+type DisplayInfo struct {
+	Area    int    `json:"area"`
+	Clear   string `json:"clear"`
+	Beep    bool   `json:"beep"`
+	Seconds int    `json:"seconds,omitempty"`
+	Line1   string `json:"line1"`
+	Line2   string `json:"line2,omitempty"`
+}
+
+// parseDisplayArgs parses the arguments to the display operation, ie.
+// <area> [acknowledge] [beep] [timeout=<duration>] <line1> [line2].
+// Messages are displayed until acknowledged, by pressing the * key, unless
+// a timeout is specified without acknowledge.
+func parseDisplayArgs(args []string) (protocol.DisplayMessageRequest, error) {
+	var req protocol.DisplayMessageRequest
+	if len(args) == 0 {
+		return req, fmt.Errorf("missing area number")
+	}
+	area, err := areaArg(args)
+	if err != nil {
+		return req, err
+	}
+	req.Area = area
+	args = args[1:]
+	acknowledge := false
+options:
+	for ; len(args) > 0; args = args[1:] {
+		switch a := args[0]; {
+		case a == "acknowledge":
+			acknowledge = true
+		case a == "beep":
+			req.Beep = true
+		case strings.HasPrefix(a, "timeout="):
+			d, err := time.ParseDuration(strings.TrimPrefix(a, "timeout="))
+			if err != nil || d < time.Second {
+				return req, fmt.Errorf("invalid timeout: %v", a)
+			}
+			req.Seconds = int(d.Round(time.Second) / time.Second)
+		default:
+			break options
+		}
+	}
+	switch len(args) {
+	case 1:
+		req.Line1 = args[0]
+	case 2:
+		req.Line1, req.Line2 = args[0], args[1]
+	default:
+		return req, fmt.Errorf("one or two lines of text must be specified")
+	}
+	req.Clear = protocol.DisplayUntilAcknowledge
+	if req.Seconds > 0 && !acknowledge {
+		req.Clear = protocol.DisplayUntilTimeout
+	}
+	return req, nil
+}
+
+func (m1 *M1xep) display(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	req, err := parseDisplayArgs(args.Args)
+	if err != nil {
+		return nil, err
+	}
+	if err := protocol.DisplayMessage(ctx, sess, req); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(args.Writer, "display: area %v: %q %q (%v)\n", req.Area, req.Line1, req.Line2, req.Clear)
+	return DisplayInfo{
+		Area:    req.Area,
+		Clear:   req.Clear.String(),
+		Beep:    req.Beep,
+		Seconds: req.Seconds,
+		Line1:   req.Line1,
+		Line2:   req.Line2,
+	}, nil
+}
+
+func (m1 *M1xep) clearDisplay(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	area, err := areaArg(args.Args)
+	if err != nil {
+		return nil, err
+	}
+	if err := protocol.ClearDisplayMessage(ctx, sess, area); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(args.Writer, "display-clear: area %v\n", area)
+	return DisplayInfo{Area: area, Clear: protocol.DisplayClearNow.String()}, nil
+}
+
+type KeypadConfig struct {
+	KeypadNumber int `yaml:"keypad"`
+}
+
+type Keypad struct {
+	m1DeviceBase
+	devices.DeviceBase[KeypadConfig]
+}
+
+func NewKeypad(_ devices.Options) *Keypad {
+	return &Keypad{
+		m1DeviceBase: m1DeviceBase{},
+	}
+}
+
+func (k *Keypad) UnmarshalYAML(node *yaml.Node) error {
+	if err := node.Decode(&k.DeviceConfigCustom); err != nil {
+		return err
+	}
+	if kn := k.DeviceConfigCustom.KeypadNumber; kn < 1 || kn > protocol.NumKeypads {
+		return fmt.Errorf("invalid keypad number: %v", kn)
+	}
+	return nil
+}
+
+func (k *Keypad) Operations() map[string]devices.Operation {
+	return map[string]devices.Operation{
+		"press":  k.Press,
+		"status": k.Status,
+	}
+}
+
+func (k *Keypad) OperationsHelp() map[string]string {
+	return map[string]string{
+		"press":  "press the specified key on the keypad, one of: " + strings.Join(protocol.FunctionKeyNames(), ", "),
+		"status": "display the keypad's area, function key illumination and beep and chime state",
+	}
+}
+
+func (k *Keypad) Conditions() map[string]devices.Condition {
+	return map[string]devices.Condition{
+		"beeping":     k.condition(func(ks KeypadInfo) bool { return ks.beepChime.Beeping() }),
+		"chime":       k.condition(func(ks KeypadInfo) bool { return ks.beepChime&(protocol.Chime|protocol.Voice) != 0 }),
+		"illuminated": k.Illuminated,
+	}
+}
+
+func (k *Keypad) ConditionsHelp() map[string]string {
+	return map[string]string{
+		"beeping":     "true if the keypads in the keypad's area are beeping",
+		"chime":       "true if chime or voice chime mode is enabled for the keypad's area",
+		"illuminated": "true if the specified function key, f1 to f6, is illuminated or blinking",
+	}
+}
+
+type KeypadInfo struct {
+	Keypad               int               `json:"keypad"`
+	Area                 int               `json:"area"`
+	Illumination         map[string]string `json:"illumination,omitempty"`
+	CodeRequiredToBypass bool              `json:"code_required_to_bypass"`
+	BeepChime            string            `json:"beep_chime"`
+
+	illumination [protocol.NumFunctionKeys]protocol.Illumination
+	beepChime    protocol.BeepChime
+}
+
+type KeypadPressInfo struct {
+	Keypad    int    `json:"keypad"`
+	Key       string `json:"key"`
+	Area      int    `json:"area"`
+	ChimeMode string `json:"chime_mode"`
+}
+
+func (k *Keypad) area(ctx context.Context, sess *streamconn.Session) (int, error) {
+	areas, err := protocol.GetKeypadAreas(ctx, sess)
+	if err != nil {
+		return 0, err
+	}
+	return areas.Areas[k.DeviceConfigCustom.KeypadNumber-1], nil
+}
+
+func (k *Keypad) Press(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	if len(opts.Args) != 1 {
+		return nil, fmt.Errorf("press requires a key")
+	}
+	key, err := protocol.ParseFunctionKey(opts.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	kn := k.DeviceConfigCustom.KeypadNumber
+	return k.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, opts devices.OperationArgs) (any, error) {
+		reply, err := protocol.PressFunctionKey(ctx, sess, kn, key)
+		if err != nil {
+			return nil, err
+		}
+		area, err := k.area(ctx, sess)
+		if err != nil {
+			return nil, err
+		}
+		info := KeypadPressInfo{Keypad: kn, Key: key.String(), Area: area}
+		if area >= 1 && area <= protocol.NumAreas {
+			info.ChimeMode = reply.ChimeModes[area-1].String()
+		}
+		if opts.Writer != nil {
+			fmt.Fprintf(opts.Writer, "keypad: %v, pressed %v, area %v, chime mode %v\n", kn, key, area, info.ChimeMode)
+		}
+		return info, nil
+	}, opts)
+}
+
+func (k *Keypad) status(ctx context.Context, opts devices.OperationArgs) (KeypadInfo, error) {
+	ctx, sess, err := k.m1.session(ctx)
+	if err != nil {
+		return KeypadInfo{}, err
+	}
+	defer sess.Release()
+	kn := k.DeviceConfigCustom.KeypadNumber
+	area, err := k.area(ctx, sess)
+	if err != nil {
+		return KeypadInfo{}, err
+	}
+	kc, err := protocol.GetKeypadStatus(ctx, sess, kn)
+	if err != nil {
+		return KeypadInfo{}, err
+	}
+	ks := KeypadInfo{
+		Keypad:               kn,
+		Area:                 area,
+		Illumination:         map[string]string{},
+		CodeRequiredToBypass: kc.CodeRequiredToBypass,
+		illumination:         kc.Illumination,
+	}
+	for i, il := range kc.Illumination {
+		ks.Illumination[fmt.Sprintf("f%v", i+1)] = il.String()
+	}
+	if area >= 1 && area <= protocol.NumAreas {
+		ks.beepChime = kc.BeepChime[area-1]
+	}
+	ks.BeepChime = ks.beepChime.String()
+	if opts.Writer != nil {
+		_, _ = opts.Writer.Write(fmt.Appendf(nil, "keypad: %v, area %v, illumination %v, code required to bypass %v, beep/chime %v",
+			kn, area, kc.Illumination, kc.CodeRequiredToBypass, ks.BeepChime))
+	}
+	if k.logger != nil {
+		k.logger.Info("keypad-status", "keypad", kn, "area", area, "illumination", kc.Illumination, "code-required-to-bypass", kc.CodeRequiredToBypass, "beep-chime", ks.BeepChime)
+	}
+	return ks, nil
+}
+
+func (k *Keypad) Status(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	return k.status(ctx, opts)
+}
+
+func (k *Keypad) condition(pred func(KeypadInfo) bool) devices.Condition {
+	return func(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+		ks, err := k.status(ctx, opts)
+		if err != nil {
+			return nil, false, err
+		}
+		return nil, pred(ks), nil
+	}
+}
+
+func (k *Keypad) Illuminated(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	if len(opts.Args) != 1 {
+		return nil, false, fmt.Errorf("a function key, f1 to f6, is required")
+	}
+	key, err := protocol.ParseFunctionKey(opts.Args[0])
+	if err != nil || key < protocol.F1FunctionKey || key > protocol.F6FunctionKey {
+		return nil, false, fmt.Errorf("invalid function key: %q, must be f1 to f6", opts.Args[0])
+	}
+	ks, err := k.status(ctx, devices.OperationArgs{Writer: opts.Writer})
+	if err != nil {
+		return nil, false, err
+	}
+	il := ks.illumination[key-protocol.F1FunctionKey]
+	return il.String(), il != protocol.IlluminationOff, nil
+}
+
+type KeypadAreaInfo struct {
+	Keypad int `json:"keypad"`
+	Area   int `json:"area"`
+}
+
+func (m1 *M1xep) getKeypadAreas(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	areas, err := protocol.GetKeypadAreas(ctx, sess)
+	if err != nil {
+		return nil, err
+	}
+	info := make([]KeypadAreaInfo, 0, protocol.NumKeypads)
+	for i, a := range areas.Areas {
+		fmt.Fprintf(args.Writer, "keypad: %v, area %v\n", i+1, a)
+		info = append(info, KeypadAreaInfo{Keypad: i + 1, Area: a})
+	}
+	return info, nil
+}
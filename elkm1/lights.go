@@ -0,0 +1,260 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+	"gopkg.in/yaml.v3"
+)
+
+// lightingStatus caches the level of every lighting device. It is filled
+// using a single set of ps requests and then kept up to date by the PC
+// messages sent by the M1 whenever a device changes.
+type lightingStatus struct {
+	mu     sync.Mutex
+	valid  bool
+	levels protocol.LightingStatusAll
+	// changes counts the PC messages received, changed records the count
+	// at which each device last changed and invalidated the count at which
+	// the cache was last invalidated, so that the replies to ps requests
+	// that are in flight do not overwrite more recent changes.
+	changes     int
+	changed     [protocol.NumLightingDevices]int
+	invalidated int
+}
+
+func (ls *lightingStatus) handle(_ context.Context, f protocol.Frame) {
+	var pc protocol.LightingChange
+	if err := pc.Decode(f.Data); err != nil {
+		return
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.changes++
+	if device := pc.Device(); device != 0 {
+		ls.levels[device-1] = pc.Level
+		ls.changed[device-1] = ls.changes
+		return
+	}
+	// All units/lights on/off functions apply to an entire house code
+	// and don't say which of its devices are lights, so refetch them all.
+	ls.valid, ls.invalidated = false, ls.changes
+}
+
+// invalidate discards the cached levels, eg. when a new connection is
+// established since PC messages may have been missed in the meantime.
+func (ls *lightingStatus) invalidate() {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.changes++
+	ls.valid, ls.invalidated = false, ls.changes
+}
+
+// get returns the level of every lighting device, requesting them from
+// the M1 only if they are not already cached.
+func (ls *lightingStatus) get(ctx context.Context, sess *streamconn.Session) (protocol.LightingStatusAll, error) {
+	ls.mu.Lock()
+	if ls.valid {
+		defer ls.mu.Unlock()
+		return ls.levels, nil
+	}
+	start := ls.changes
+	ls.mu.Unlock()
+	all, err := protocol.GetLightingStatusAll(ctx, sess)
+	if err != nil {
+		return protocol.LightingStatusAll{}, err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	for i, level := range all {
+		if ls.changed[i] <= start {
+			ls.levels[i] = level
+		}
+	}
+	ls.valid = ls.invalidated <= start
+	return ls.levels, nil
+}
+
+type LightConfig struct {
+	// Light is either the device number, 1 to 256, or the house code
+	// and unit, eg. A1.
+	Light string `yaml:"light"`
+}
+
+type Light struct {
+	m1DeviceBase
+	devices.DeviceBase[LightConfig]
+	device int
+}
+
+func NewLight(_ devices.Options) *Light {
+	return &Light{
+		m1DeviceBase: m1DeviceBase{},
+	}
+}
+
+func (l *Light) UnmarshalYAML(node *yaml.Node) error {
+	if err := node.Decode(&l.DeviceConfigCustom); err != nil {
+		return err
+	}
+	device, err := protocol.ParseLightingDevice(l.DeviceConfigCustom.Light)
+	if err != nil {
+		return err
+	}
+	l.device = device
+	return nil
+}
+
+func (l *Light) Operations() map[string]devices.Operation {
+	return map[string]devices.Operation{
+		"on":     l.On,
+		"off":    l.Off,
+		"toggle": l.Toggle,
+		"level":  l.Level,
+	}
+}
+
+func (l *Light) OperationsHelp() map[string]string {
+	return map[string]string{
+		"on":     "turn the light on",
+		"off":    "turn the light off",
+		"toggle": "toggle the light",
+		"level":  "set the light to the specified level, 0 to 99%, with an optional transition time, eg. 5s",
+	}
+}
+
+func (l *Light) Conditions() map[string]devices.Condition {
+	return map[string]devices.Condition{
+		"on":          l.IsOn,
+		"off":         l.IsOff,
+		"level-above": l.LevelAbove,
+		"level-below": l.LevelBelow,
+	}
+}
+
+func (l *Light) ConditionsHelp() map[string]string {
+	return map[string]string{
+		"on":          "true if the light is on at any level",
+		"off":         "true if the light is off",
+		"level-above": "true if the light's level is above the specified percentage, a light that is fully on has a level of 100",
+		"level-below": "true if the light's level is below the specified percentage, a light that is fully on has a level of 100",
+	}
+}
+
+func (l *Light) run(ctx context.Context, opts devices.OperationArgs, op func(context.Context, *streamconn.Session, int) error) (any, error) {
+	device := l.device
+	return l.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, _ devices.OperationArgs) (any, error) {
+		return nil, op(ctx, sess, device)
+	}, opts)
+}
+
+func (l *Light) On(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	return l.run(ctx, opts, protocol.LightingOn)
+}
+
+func (l *Light) Off(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	return l.run(ctx, opts, protocol.LightingOff)
+}
+
+func (l *Light) Toggle(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	return l.run(ctx, opts, protocol.LightingToggle)
+}
+
+func (l *Light) Level(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	if len(opts.Args) == 0 {
+		return nil, fmt.Errorf("level requires a level between 0 and 99")
+	}
+	level, err := strconv.Atoi(opts.Args[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid level: %v: %w", opts.Args[0], err)
+	}
+	var transition time.Duration
+	if len(opts.Args) > 1 {
+		if transition, err = time.ParseDuration(opts.Args[1]); err != nil {
+			return nil, fmt.Errorf("invalid transition time: %v: %w", opts.Args[1], err)
+		}
+	}
+	return l.run(ctx, opts, func(ctx context.Context, sess *streamconn.Session, device int) error {
+		return protocol.LightingLevel(ctx, sess, device, level, int(transition.Seconds()))
+	})
+}
+
+// brightness returns the level of the light as a percentage, a light
+// that is on, rather than dimmed, is treated as being at 100%.
+func brightness(level int) int {
+	if level == protocol.LightOn {
+		return 100
+	}
+	return level
+}
+
+func (l *Light) level(ctx context.Context, opts devices.OperationArgs) (int, error) {
+	ctx, sess, err := l.m1.session(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer sess.Release()
+	levels, err := l.m1.lighting.get(ctx, sess)
+	if err != nil {
+		return 0, err
+	}
+	level := levels[l.device-1]
+	name := protocol.LightingDeviceName(l.device)
+	if opts.Writer != nil {
+		_, _ = opts.Writer.Write(fmt.Appendf(nil, "light: %v, level %v", name, level))
+	}
+	if l.logger != nil {
+		l.logger.Info("light-status", "light", name, "level", level)
+	}
+	return level, nil
+}
+
+func (l *Light) IsOn(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	level, err := l.level(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return level, level != protocol.LightOff, nil
+}
+
+func (l *Light) IsOff(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	level, err := l.level(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return level, level == protocol.LightOff, nil
+}
+
+func (l *Light) compareLevel(ctx context.Context, opts devices.OperationArgs, cmp func(level, threshold float64) bool) (any, bool, error) {
+	if len(opts.Args) == 0 {
+		return nil, false, fmt.Errorf("a level threshold is required")
+	}
+	threshold, err := parseThreshold(opts.Args[0])
+	if err != nil {
+		return nil, false, err
+	}
+	level, err := l.level(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	b := brightness(level)
+	return b, cmp(float64(b), threshold), nil
+}
+
+func (l *Light) LevelAbove(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	return l.compareLevel(ctx, opts, func(level, threshold float64) bool { return level > threshold })
+}
+
+func (l *Light) LevelBelow(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	return l.compareLevel(ctx, opts, func(level, threshold float64) bool { return level < threshold })
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+type noIdle struct{}
+
+func (noIdle) Reset(context.Context) {}
+
+func lightingChange(house byte, unit, level int) protocol.Frame {
+	return protocol.Frame{Type: 'P', SubType: 'C',
+		Data: (&protocol.LightingChange{House: house, Unit: unit, Level: level}).Encode()}
+}
+
+func TestLightingStatus(t *testing.T) {
+	ctx := protocol.ContextWithDispatcher(context.Background(), protocol.NewDispatcher())
+	pt := newPipeTransport()
+	var mgr streamconn.SessionManager
+	sess := mgr.New(pt, noIdle{})
+	defer sess.Release()
+
+	queueStatus := func() {
+		for bank := range protocol.NumLightingBanks {
+			reply := &protocol.LightingStatusReply{Bank: bank}
+			if bank == 0 {
+				reply.Levels[0] = protocol.LightOn
+				reply.Levels[1] = 50
+			}
+			pt.lines <- string(protocol.Encode(reply))
+		}
+	}
+
+	var ls lightingStatus
+	get := func(device, level, sent int) {
+		t.Helper()
+		levels, err := ls.get(ctx, sess)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := levels[device-1], level; got != want {
+			t.Errorf("device %v: got %v, want %v", device, got, want)
+		}
+		if got, want := len(pt.waitForSent(t, sent)), sent; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	queueStatus()
+	get(1, protocol.LightOn, protocol.NumLightingBanks)
+	get(2, 50, protocol.NumLightingBanks)
+
+	// PC messages update the cache without any further requests.
+	ls.handle(ctx, lightingChange('A', 2, 20))
+	get(2, 20, protocol.NumLightingBanks)
+	ls.handle(ctx, lightingChange('P', 16, protocol.LightOn))
+	get(protocol.NumLightingDevices, protocol.LightOn, protocol.NumLightingBanks)
+
+	// Functions that apply to an entire house code invalidate the cache.
+	ls.handle(ctx, lightingChange('A', 0, int(protocol.X10AllUnitsOff)))
+	queueStatus()
+	get(2, 50, 2*protocol.NumLightingBanks)
+
+	// As does a new connection.
+	ls.invalidate()
+	queueStatus()
+	get(1, protocol.LightOn, 3*protocol.NumLightingBanks)
+}
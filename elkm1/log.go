@@ -0,0 +1,102 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+// defaultLogCount is the number of log entries returned by the log
+// operation when no count is specified.
+const defaultLogCount = 20
+
+type LogInfo struct {
+	Index  int       `json:"index"`
+	Event  int       `json:"event"`
+	Number int       `json:"number"`
+	Area   int       `json:"area"`
+	Time   time.Time `json:"time"`
+}
+
+// parseSince parses either a duration, relative to now, or an absolute
+// time in the local time zone.
+func parseSince(now time.Time, arg string) (time.Time, error) {
+	if d, err := time.ParseDuration(arg); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, arg); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, arg, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid since: %q, must be a duration or a time, eg. 24h or 2006-01-02T15:04", arg)
+}
+
+func (m1 *M1xep) readLog(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	count := defaultLogCount
+	var since time.Time
+	if len(args.Args) > 0 {
+		n, err := strconv.Atoi(args.Args[0])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid count: %v", args.Args[0])
+		}
+		count = n
+	}
+	if len(args.Args) > 1 {
+		s, err := parseSince(time.Now(), args.Args[1])
+		if err != nil {
+			return nil, err
+		}
+		since = s
+	}
+	entries := []LogInfo{}
+	err := protocol.WalkLog(ctx, sess, func(e protocol.LogEntry) bool {
+		if e.Time.Before(since) {
+			return false
+		}
+		entries = append(entries, LogInfo{Index: e.Index, Event: e.Event, Number: e.Number, Area: e.Area, Time: e.Time})
+		fmt.Fprintf(args.Writer, "%03d: %v: event %v, number %v, area %v\n", e.Index, e.Time.Format("2006-01-02 15:04"), e.Event, e.Number, e.Area)
+		return len(entries) < count
+	})
+	return entries, err
+}
+
+func (m1 *M1xep) writeLog(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	if len(args.Args) != 4 {
+		return nil, fmt.Errorf("log-write requires: alarm|restore <event> <zone> <area>")
+	}
+	var typ protocol.LogType
+	switch args.Args[0] {
+	case "alarm":
+		typ = protocol.LogAlarm
+	case "restore":
+		typ = protocol.LogAlarmRestore
+	default:
+		return nil, fmt.Errorf("invalid log type: %q, must be alarm or restore", args.Args[0])
+	}
+	var vals [3]int
+	for i, arg := range args.Args[1:] {
+		v, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %v: %w", arg, err)
+		}
+		vals[i] = v
+	}
+	if err := protocol.WriteLog(ctx, sess, typ, vals[0], vals[1], vals[2]); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(args.Writer, "log: wrote %v event %v, zone %v, area %v\n", args.Args[0], vals[0], vals[1], vals[2])
+	return nil, nil
+}
@@ -19,15 +19,40 @@ func NewController(typ string, opts devices.Options) (devices.Controller, error)
 }
 
 func NewDevice(typ string, opts devices.Options) (devices.Device, error) {
-	if typ == "elk-m1zone" {
+	switch typ {
+	case "elk-m1zone":
 		return NewZone(opts), nil
+	case "elk-m1area":
+		return NewArea(opts), nil
+	case "elk-m1output":
+		return NewOutput(opts), nil
+	case "elk-m1task":
+		return NewTask(opts), nil
+	case "elk-m1thermostat":
+		return NewThermostat(opts), nil
+	case "elk-m1light":
+		return NewLight(opts), nil
+	case "elk-m1counter":
+		return NewCounter(opts), nil
+	case "elk-m1customvalue":
+		return NewCustomValue(opts), nil
+	case "elk-m1keypad":
+		return NewKeypad(opts), nil
 	}
 	return nil, fmt.Errorf("unsupported elk m1 device type %s", typ)
 }
 
 func SupportedDevices() devices.SupportedDevices {
 	return devices.SupportedDevices{
-		"elk-m1zone": NewDevice,
+		"elk-m1zone":        NewDevice,
+		"elk-m1area":        NewDevice,
+		"elk-m1output":      NewDevice,
+		"elk-m1task":        NewDevice,
+		"elk-m1thermostat":  NewDevice,
+		"elk-m1light":       NewDevice,
+		"elk-m1counter":     NewDevice,
+		"elk-m1customvalue": NewDevice,
+		"elk-m1keypad":      NewDevice,
 	}
 }
 
@@ -6,7 +6,11 @@ package elkm1
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"cloudeng.io/cmdutil/unsafekeystore"
@@ -27,18 +31,39 @@ type M1Config struct {
 	KeyID      string        `yaml:"key_id"`
 	TLSVersion string        `yaml:"tls_version"`
 	Verbose    bool          `yaml:"verbose"`
+	// UserCodeKeyID is the keystore key_id whose token is the user code
+	// used for arming, disarming and other operations that require one.
+	UserCodeKeyID string `yaml:"user_code_key_id"`
 }
 
 type M1xep struct {
 	devices.ControllerBase[M1Config]
-	mgr      *streamconn.SessionManager
-	ondemand *netutil.OnDemandConnection[streamconn.Transport, *M1xep]
+	mgr        *streamconn.SessionManager
+	ondemand   *netutil.OnDemandConnection[streamconn.Transport, *M1xep]
+	dispatcher *protocol.Dispatcher
+	tasks      *taskActivations
+	alarms     *alarmReports
+	entryExit  *entryExitTimers
+	lighting   *lightingStatus
+
+	versionMu   sync.Mutex
+	version     *protocol.VersionReply
+	versionConn streamconn.Transport
 }
 
 func NewM1XEP(_ devices.Options) *M1xep {
 	m1 := &M1xep{
-		mgr: &streamconn.SessionManager{},
+		mgr:        &streamconn.SessionManager{},
+		dispatcher: protocol.NewDispatcher(),
+		tasks:      newTaskActivations(),
+		alarms:     &alarmReports{},
+		entryExit:  newEntryExitTimers(),
+		lighting:   &lightingStatus{},
 	}
+	m1.dispatcher.Handle('T', 'C', m1.tasks.handle)
+	m1.dispatcher.Handle('A', 'R', m1.alarms.handle)
+	m1.dispatcher.Handle('E', 'E', m1.entryExit.handle)
+	m1.dispatcher.Handle('P', 'C', m1.lighting.handle)
 	m1.ondemand = netutil.NewOnDemandConnection(m1)
 	return m1
 }
@@ -64,25 +89,133 @@ func (m1 *M1xep) Implementation() any {
 	return m1
 }
 
+// Dispatcher returns the dispatcher used to deliver the unsolicited
+// messages sent by the M1XEP, handlers may be registered with it
+// to receive zone changes, arming updates etc.
+func (m1 *M1xep) Dispatcher() *protocol.Dispatcher {
+	return m1.dispatcher
+}
+
+func armingOperationName(level protocol.ArmingLevel) string {
+	if level == protocol.Disarm {
+		return level.String()
+	}
+	return "arm-" + level.String()
+}
+
 func (m1 *M1xep) OperationsHelp() map[string]string {
-	return map[string]string{
-		"gettime":    "get the current time from the M1XEP",
-		"zonenames":  "get the names of all zones",
-		"zonestatus": "get the status of all zones",
+	help := map[string]string{
+		"gettime":         "get the current time from the M1XEP",
+		"settime":         "set the M1's clock to the host's time and report its drift: [time-zone] [dst|std], the time zone defaults to the host's and dst|std to whether daylight saving time is in effect",
+		"version":         "get the M1 and M1XEP firmware versions",
+		"monitor":         "display unsolicited messages for the specified duration (default 1m)",
+		"task":            "activate the task with the specified number or name",
+		"trigger":         "momentarily violate the specified zone, as if it had been opened",
+		"bypass":          "bypass the specified zone, in the specified area (default 1), using the configured user code: <zone> [area]",
+		"unbypass":        "unbypass the specified zone, in the specified area (default 1), using the configured user code: <zone> [area]",
+		"bypass-violated": "bypass all violated burglar zones in the specified area (default 1) using the configured user code",
+		"unbypass-all":    "unbypass all burglar zones in the specified area (default 1) using the configured user code",
+		"zonenames":       "get the names of all zones",
+		"names":           "get the names of all entities of the specified kind, one of: " + strings.Join(descriptionTypeNames(), ", "),
+		"zonestatus":      "get the status of all zones",
+		"alarms":          "get the zones that are in alarm and the type of each alarm",
+		"log":             "display the most recent log entries, optionally limited to the specified count (default 20) and to those since a time or duration, eg. 24h",
+		"log-write":       "write an event to the log: alarm|restore <event> <zone> <area>",
+		"display":         "display one or two lines of up to 16 characters on the keypads in an area until acknowledged with the * key or a timeout expires: <area> [acknowledge] [beep] [timeout=<duration>] <line1> [line2]",
+		"display-clear":   "clear any message displayed on the keypads in the specified area (default 1)",
+		"trouble":         "get the system trouble status, eg. ac-fail, low-battery",
+		"keypad-areas":    "get the area that each keypad is assigned to",
+		"speak":           "speak the specified sequence of words, by name or number, eg. garage door is-open, at the voice/siren output",
+		"speak-phrase":    "speak the specified sequence of phrases, by name or number, eg. system-is-armed, at the voice/siren output",
+		"temperatures":    "get the temperatures of all keypads and zone probes, or of the devices in the specified group (probe, keypad or thermostat) and optional device number",
 	}
+	for _, level := range protocol.ArmingLevels() {
+		help[armingOperationName(level)] = fmt.Sprintf("%v the specified area (default 1) using the configured user code", armingOperationName(level))
+	}
+	return help
 }
 
 func (m1 *M1xep) Operations() map[string]devices.Operation {
+	ops := m1.operations()
+	for _, level := range protocol.ArmingLevels() {
+		ops[armingOperationName(level)] = func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.arm(level), args)
+		}
+	}
+	return ops
+}
+
+func (m1 *M1xep) operations() map[string]devices.Operation {
 	return map[string]devices.Operation{
 		"gettime": func(ctx context.Context, args devices.OperationArgs) (any, error) {
 			return m1.runOperation(ctx, m1.getTime, args)
 		},
+		"settime": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.setTime, args)
+		},
+		"display": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.display, args)
+		},
+		"display-clear": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.clearDisplay, args)
+		},
+		"alarms": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.getZoneAlarms, args)
+		},
+		"trouble": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.getTrouble, args)
+		},
+		"keypad-areas": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.getKeypadAreas, args)
+		},
+		"speak": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.speakWords, args)
+		},
+		"speak-phrase": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.speakPhrases, args)
+		},
+		"version": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.getVersion, args)
+		},
+		"monitor": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.monitor(ctx, args)
+		},
+		"bypass": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.bypassZone(true), args)
+		},
+		"unbypass": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.bypassZone(false), args)
+		},
+		"bypass-violated": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.bypassArea(true), args)
+		},
+		"unbypass-all": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.bypassArea(false), args)
+		},
+		"trigger": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.triggerZone, args)
+		},
+		"task": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.activateTask, args)
+		},
 		"zonenames": func(ctx context.Context, args devices.OperationArgs) (any, error) {
 			return m1.runOperation(ctx, m1.getZoneNames, args)
 		},
+		"names": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.getNames, args)
+		},
 		"zonestatus": func(ctx context.Context, args devices.OperationArgs) (any, error) {
 			return m1.runOperation(ctx, m1.getZoneStatus, args)
 		},
+		"temperatures": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.getTemperatures, args)
+		},
+		"log": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.readLog, args)
+		},
+		"log-write": func(ctx context.Context, args devices.OperationArgs) (any, error) {
+			return m1.runOperation(ctx, m1.writeLog, args)
+		},
 	}
 }
 
@@ -104,7 +237,7 @@ type ZoneInfo struct {
 func (m1 *M1xep) getTime(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
 	t, dst, err := protocol.GetTime(ctx, sess)
 	dstMsg := "(standard time)"
-	if !dst {
+	if dst {
 		dstMsg = "(daylight saving time)"
 	}
 	if err == nil {
@@ -115,23 +248,216 @@ func (m1 *M1xep) getTime(ctx context.Context, sess *streamconn.Session, args dev
 	}{Time: t.String()}, err
 }
 
-func (m1 *M1xep) getZoneNames(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
-	defs, err := protocol.GetZoneDefinitions(ctx, sess)
+type VersionInfo struct {
+	M1    string `json:"m1"`
+	M1XEP string `json:"m1xep,omitempty"`
+}
+
+func (m1 *M1xep) getVersion(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	v, ok := m1.Version()
+	if !ok {
+		var err error
+		if v, err = protocol.GetVersion(ctx, sess); err != nil {
+			return nil, err
+		}
+	}
+	vi := VersionInfo{M1: v.M1.String()}
+	if !v.M1XEP.IsZero() {
+		vi.M1XEP = v.M1XEP.String()
+	}
+	fmt.Fprintf(args.Writer, "m1: %v, m1xep: %v\n", vi.M1, v.M1XEP)
+	return vi, nil
+}
+
+type MessageInfo struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+}
+
+// monitor reports the unsolicited messages received by the connection's
+// reader. Unlike other operations it does not hold a session whilst doing
+// so since that would block all other operations until it finishes.
+func (m1 *M1xep) monitor(ctx context.Context, args devices.OperationArgs) (any, error) {
+	duration := time.Minute
+	if len(args.Args) > 0 {
+		d, err := time.ParseDuration(args.Args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration: %v: %w", args.Args[0], err)
+		}
+		duration = d
+	}
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	// The session is only needed to establish the connection, and hence
+	// start its reader, and to obtain its idle timer.
+	ctx, sess, idle, err := m1.sessionWithIdle(ctx)
 	if err != nil {
 		return nil, err
 	}
-	names := []string{}
-	for i, def := range defs {
-		if def == protocol.DisabledZoneType {
-			names = append(names, "disabled")
-			continue
+	sess.Release()
+	var mu sync.Mutex
+	msgs := []MessageInfo{}
+	remove := m1.dispatcher.HandleAll(func(ctx context.Context, f protocol.Frame) {
+		// Keep the connection open whilst monitoring.
+		idle.Reset(ctx)
+		mi := MessageInfo{Type: string([]byte{f.Type, f.SubType}), Data: string(f.Data)}
+		mu.Lock()
+		defer mu.Unlock()
+		msgs = append(msgs, mi)
+		if m, err := f.Message(); err == nil {
+			fmt.Fprintf(args.Writer, "%v: %+v\n", mi.Type, m)
+		} else {
+			fmt.Fprintf(args.Writer, "%v: %v: %v\n", mi.Type, mi.Data, err)
 		}
-		z := i + 1
-		name, err := protocol.GetZoneName(ctx, sess, z)
+	})
+	defer remove()
+	err = m1.dispatcher.Wait(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return msgs, err
+}
+
+// userCode returns the user code stored in the keystore under the
+// configured user_code_key_id.
+func (m1 *M1xep) userCode(ctx context.Context) (string, error) {
+	id := m1.ControllerConfigCustom.UserCodeKeyID
+	if id == "" {
+		return "", fmt.Errorf("user_code_key_id is not configured")
+	}
+	keys := unsafekeystore.AuthFromContextForID(ctx, id)
+	if keys.Token == "" {
+		return "", fmt.Errorf("no user code found for key_id: %v", id)
+	}
+	return keys.Token, nil
+}
+
+// areaArg returns the area number specified as the first argument,
+// or area 1 if none is specified.
+func areaArg(args []string) (int, error) {
+	if len(args) == 0 {
+		return 1, nil
+	}
+	area, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid area number: %v: %w", args[0], err)
+	}
+	if area < 1 || area > protocol.NumAreas {
+		return 0, fmt.Errorf("invalid area number: %v", area)
+	}
+	return area, nil
+}
+
+type ArmingInfo struct {
+	Area  int    `json:"area"`
+	Level string `json:"level"`
+}
+
+func (m1 *M1xep) arm(level protocol.ArmingLevel) func(context.Context, *streamconn.Session, devices.OperationArgs) (any, error) {
+	return func(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+		area, err := areaArg(args.Args)
+		if err != nil {
+			return nil, err
+		}
+		code, err := m1.userCode(ctx)
 		if err != nil {
 			return nil, err
 		}
-		names = append(names, name)
+		if err := protocol.Arm(ctx, sess, area, level, code); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(args.Writer, "area %v: %v\n", area, level)
+		return ArmingInfo{Area: area, Level: level.String()}, nil
+	}
+}
+
+type BypassInfo struct {
+	Zone     int  `json:"zone,omitempty"`
+	Area     int  `json:"area,omitempty"`
+	Bypassed bool `json:"bypassed"`
+}
+
+func (m1 *M1xep) bypassZone(bypass bool) func(context.Context, *streamconn.Session, devices.OperationArgs) (any, error) {
+	return func(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+		zn, err := zoneArg(args.Args)
+		if err != nil {
+			return nil, err
+		}
+		// The user code is checked against the area that the zone
+		// belongs to.
+		area, err := areaArg(args.Args[1:])
+		if err != nil {
+			return nil, err
+		}
+		code, err := m1.userCode(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := protocol.SetZoneBypass(ctx, sess, zn, area, bypass, code); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(args.Writer, "zone %v (area %v): bypassed %v\n", zn, area, bypass)
+		return BypassInfo{Zone: zn, Area: area, Bypassed: bypass}, nil
+	}
+}
+
+// zoneArg returns the zone number specified as the first argument.
+func zoneArg(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("a zone number must be specified")
+	}
+	zn, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid zone number: %v: %w", args[0], err)
+	}
+	return zn, nil
+}
+
+func (m1 *M1xep) triggerZone(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	zn, err := zoneArg(args.Args)
+	if err != nil {
+		return nil, err
+	}
+	if err := protocol.TriggerZone(ctx, sess, zn); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(args.Writer, "zone %v: triggered\n", zn)
+	return ZoneInfo{Zone: zn}, nil
+}
+
+func (m1 *M1xep) bypassArea(bypass bool) func(context.Context, *streamconn.Session, devices.OperationArgs) (any, error) {
+	return func(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+		area, err := areaArg(args.Args)
+		if err != nil {
+			return nil, err
+		}
+		code, err := m1.userCode(ctx)
+		if err != nil {
+			return nil, err
+		}
+		reply, err := protocol.BypassAreaZones(ctx, sess, area, bypass, code)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(args.Writer, "area %v: bypassed %v\n", area, reply.Bypassed)
+		return BypassInfo{Area: area, Bypassed: reply.Bypassed}, nil
+	}
+}
+
+func (m1 *M1xep) getZoneNames(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	defs, err := protocol.GetZoneDefinitions(ctx, sess)
+	if err != nil {
+		return nil, err
+	}
+	names := map[int]string{}
+	err = protocol.WalkNames(ctx, sess, protocol.ZoneDescription, func(d protocol.Description) bool {
+		names[d.Index] = d.Name
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	zi := []ZoneInfo{}
@@ -139,12 +465,47 @@ func (m1 *M1xep) getZoneNames(ctx context.Context, sess *streamconn.Session, arg
 		if def == protocol.DisabledZoneType {
 			continue
 		}
-		zi = append(zi, ZoneInfo{Zone: i + 1, Name: names[i]})
-		fmt.Fprintf(args.Writer, "zone %v: %v: %v\n", i+1, def, names[i])
+		zi = append(zi, ZoneInfo{Zone: i + 1, Name: names[i+1]})
+		fmt.Fprintf(args.Writer, "zone %v: %v: %v\n", i+1, def, names[i+1])
 	}
 	return zi, nil
 }
 
+type NameInfo struct {
+	Kind  string `json:"kind"`
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+}
+
+func descriptionTypeNames() []string {
+	names := []string{}
+	for _, dt := range protocol.DescriptionTypes() {
+		names = append(names, dt.String())
+	}
+	return names
+}
+
+func (m1 *M1xep) getNames(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	if len(args.Args) != 1 {
+		return nil, fmt.Errorf("names requires one of: %v", strings.Join(descriptionTypeNames(), ", "))
+	}
+	kind, err := protocol.ParseDescriptionType(args.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	names, err := protocol.GetNames(ctx, sess, kind)
+	if err != nil {
+		return nil, err
+	}
+	ni := []NameInfo{}
+	for _, n := range names {
+		name := strings.TrimSpace(n.Name)
+		ni = append(ni, NameInfo{Kind: kind.String(), Index: n.Index, Name: name})
+		fmt.Fprintf(args.Writer, "%v %v: %v\n", kind, n.Index, name)
+	}
+	return ni, nil
+}
+
 func (m1 *M1xep) getZoneStatus(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
 	status, err := protocol.GetZoneStatusAll(ctx, sess)
 	if err != nil {
@@ -161,6 +522,36 @@ func (m1 *M1xep) getZoneStatus(ctx context.Context, sess *streamconn.Session, ar
 	return zi, nil
 }
 
+type ZoneAlarmInfo struct {
+	Zone       int    `json:"zone"`
+	Name       string `json:"name,omitempty"`
+	Definition string `json:"definition"`
+	Alarm      string `json:"alarm"`
+}
+
+func (m1 *M1xep) getZoneAlarms(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	alarms, err := protocol.GetAlarmsByZone(ctx, sess)
+	if err != nil {
+		return nil, err
+	}
+	za := []ZoneAlarmInfo{}
+	for i, def := range alarms {
+		if def == protocol.DisabledZoneType {
+			continue
+		}
+		name, err := protocol.GetZoneName(ctx, sess, i+1)
+		if err != nil {
+			return nil, err
+		}
+		za = append(za, ZoneAlarmInfo{Zone: i + 1, Name: name, Definition: def.String(), Alarm: def.Alarm().String()})
+		fmt.Fprintf(args.Writer, "zone %v: %v: %v (%v)\n", i+1, name, def.Alarm(), def)
+	}
+	if len(za) == 0 {
+		fmt.Fprintf(args.Writer, "no zones in alarm\n")
+	}
+	return za, nil
+}
+
 func (m1 *M1xep) connectTLS(ctx context.Context, idle netutil.IdleReset, version string) (streamconn.Transport, error) {
 	conn, err := tls.Dial(ctx, m1.ControllerConfigCustom.IPAddress, version, m1.Timeout)
 	if err != nil {
@@ -181,32 +572,103 @@ func (m1 *M1xep) connectTLS(ctx context.Context, idle netutil.IdleReset, version
 }
 
 func (m1 *M1xep) Connect(ctx context.Context, idle netutil.IdleReset) (streamconn.Transport, error) {
+	var conn streamconn.Transport
+	var err error
 	if m1.ControllerConfigCustom.TLSVersion != "" {
-		return m1.connectTLS(ctx, idle, m1.ControllerConfigCustom.TLSVersion)
+		conn, err = m1.connectTLS(ctx, idle, m1.ControllerConfigCustom.TLSVersion)
+	} else {
+		conn, err = telnet.Dial(ctx, m1.ControllerConfigCustom.IPAddress, m1.Timeout)
 	}
-	conn, err := telnet.Dial(ctx, m1.ControllerConfigCustom.IPAddress, m1.Timeout)
 	if err != nil {
 		return nil, err
 	}
+	// The reader delivers unsolicited messages for as long as the
+	// connection is open, ie. until it has been idle for keep_alive,
+	// which it never is whilst an alarm receiver is set.
+	rctx := ctxlog.WithAttributes(context.WithoutCancel(ctx), "protocol", "elk-m1xep")
+	m1.lighting.invalidate()
+	m1.dispatcher.Start(rctx, conn, m1.Timeout)
 	return conn, nil
 }
 
+// versionTimeout is how long to wait for the reply to the vn request
+// issued for every new connection, M1s that predate it do not reply.
+const versionTimeout = 5 * time.Second
+
+// connectionVersion returns the M1 and M1XEP firmware versions for conn,
+// detecting them using the first session created for each connection
+// rather than whilst connecting, since the connection lock is held then.
+// Sessions are exclusive and hence vn is only issued once per connection.
+// Failures, including firmware that predates vn, are logged and leave the
+// versions unknown so that no requests are refused.
+func (m1 *M1xep) connectionVersion(ctx context.Context, conn streamconn.Transport, sess *streamconn.Session) (protocol.VersionReply, bool) {
+	m1.versionMu.Lock()
+	if m1.versionConn == conn {
+		defer m1.versionMu.Unlock()
+		if m1.version == nil {
+			return protocol.VersionReply{}, false
+		}
+		return *m1.version, true
+	}
+	m1.versionMu.Unlock()
+	v, err := protocol.DetectVersion(ctx, sess, min(versionTimeout, m1.Timeout))
+	m1.versionMu.Lock()
+	defer m1.versionMu.Unlock()
+	m1.versionConn = conn
+	if err != nil {
+		ctxlog.Info(ctx, "elk-m1: failed to obtain firmware versions", "err", err)
+		m1.version = nil
+		return protocol.VersionReply{}, false
+	}
+	ctxlog.Info(ctx, "elk-m1: firmware versions", "m1", v.M1.String(), "m1xep", v.M1XEP.String())
+	m1.version = &v
+	return v, true
+}
+
+// Version returns the M1 and M1XEP firmware versions recorded for the
+// current connection, and false if they are not known.
+func (m1 *M1xep) Version() (protocol.VersionReply, bool) {
+	m1.versionMu.Lock()
+	defer m1.versionMu.Unlock()
+	if m1.version == nil {
+		return protocol.VersionReply{}, false
+	}
+	return *m1.version, true
+}
+
 func (m1 *M1xep) Disconnect(ctx context.Context, conn streamconn.Transport) error {
+	m1.versionMu.Lock()
+	if m1.versionConn == conn {
+		m1.version, m1.versionConn = nil, nil
+	}
+	m1.versionMu.Unlock()
 	return conn.Close(ctx)
 }
 
 // Session returns an authenticated session to the QS processor. If
 // an error is encountered then an error session is returned.
 func (m1 *M1xep) session(ctx context.Context) (context.Context, *streamconn.Session, error) {
+	ctx, session, _, err := m1.sessionWithIdle(ctx)
+	return ctx, session, err
+}
+
+// sessionWithIdle is like session but also returns the connection's
+// idle timer.
+func (m1 *M1xep) sessionWithIdle(ctx context.Context) (context.Context, *streamconn.Session, netutil.IdleReset, error) {
 	ctx = ctxlog.WithAttributes(ctx, "protocol", "elk-m1xep")
 	conn, idle, err := m1.ondemand.Connection(ctx)
 	if err != nil {
-		return ctx, nil, err
+		return ctx, nil, nil, err
 	}
+	ctx = protocol.ContextWithDispatcher(ctx, m1.dispatcher)
 	ctx, session := m1.mgr.NewWithContext(ctx, conn, idle)
-	return ctx, session, nil
+	if v, ok := m1.connectionVersion(ctx, conn, session); ok {
+		ctx = protocol.ContextWithVersion(ctx, v.M1)
+	}
+	return ctx, session, idle, nil
 }
 
 func (m1 *M1xep) Close(ctx context.Context) error {
+	m1.alarms.stopKeepingConnected()
 	return m1.ondemand.Close(ctx)
 }
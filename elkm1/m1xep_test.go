@@ -0,0 +1,60 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestConnectionVersion(t *testing.T) {
+	m1 := NewM1XEP(devices.Options{})
+	m1.Timeout = time.Minute
+	ctx := protocol.ContextWithDispatcher(context.Background(), m1.dispatcher)
+
+	version := func(pt *pipeTransport) (protocol.VersionReply, bool) {
+		sess := m1.mgr.New(pt, noIdle{})
+		defer sess.Release()
+		return m1.connectionVersion(ctx, pt, sess)
+	}
+
+	// vn is issued once for each connection.
+	pt, _ := startReader(m1)
+	pt.onSend = func(string) {
+		pt.lines <- "36VN05010C0103020000000000000000000000000000000000000074\r\n"
+	}
+	for range 2 {
+		v, ok := version(pt)
+		if !ok {
+			t.Fatalf("version not detected")
+		}
+		if got, want := v.M1, (protocol.Version{Major: 5, Minor: 1, Patch: 12}); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := len(pt.waitForSent(t, 1)), 1; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	// A new connection to firmware that does not reply to vn leaves the
+	// version unknown, and vn is tried twice.
+	m1.Timeout = 10 * time.Millisecond
+	pt, _ = startReader(m1)
+	for range 2 {
+		if _, ok := version(pt); ok {
+			t.Errorf("version should not be known")
+		}
+		if got, want := len(pt.waitForSent(t, 2)), 2; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if _, ok := m1.Version(); ok {
+		t.Errorf("version should not be known")
+	}
+}
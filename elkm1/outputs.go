@@ -0,0 +1,164 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+	"gopkg.in/yaml.v3"
+)
+
+type OutputConfig struct {
+	OutputNumber int `yaml:"output"`
+}
+
+type Output struct {
+	m1DeviceBase
+	devices.DeviceBase[OutputConfig]
+}
+
+func NewOutput(_ devices.Options) *Output {
+	return &Output{
+		m1DeviceBase: m1DeviceBase{},
+	}
+}
+
+func (o *Output) UnmarshalYAML(node *yaml.Node) error {
+	if err := node.Decode(&o.DeviceConfigCustom); err != nil {
+		return err
+	}
+	if on := o.DeviceConfigCustom.OutputNumber; on < 1 || on > protocol.NumOutputs {
+		return fmt.Errorf("invalid output number: %v", on)
+	}
+	return nil
+}
+
+func (o *Output) Operations() map[string]devices.Operation {
+	return map[string]devices.Operation{
+		"on":     o.On,
+		"off":    o.Off,
+		"toggle": o.Toggle,
+		"pulse":  o.Pulse,
+	}
+}
+
+func (o *Output) OperationsHelp() map[string]string {
+	return map[string]string{
+		"on":     "turn the output on, indefinitely or for the optionally specified duration, eg. 30s",
+		"off":    "turn the output off",
+		"toggle": "toggle the output",
+		"pulse":  "turn the output on for the specified duration, eg. 5s",
+	}
+}
+
+func (o *Output) Conditions() map[string]devices.Condition {
+	return map[string]devices.Condition{
+		"on":  o.IsOn,
+		"off": o.IsOff,
+	}
+}
+
+func (o *Output) ConditionsHelp() map[string]string {
+	return map[string]string{
+		"on":  "true if the output is on",
+		"off": "true if the output is off",
+	}
+}
+
+func parseOutputDuration(arg string) (time.Duration, error) {
+	d, err := time.ParseDuration(arg)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %v: %w", arg, err)
+	}
+	return d, nil
+}
+
+func (o *Output) turnOn(ctx context.Context, opts devices.OperationArgs, duration time.Duration) (any, error) {
+	on := o.DeviceConfigCustom.OutputNumber
+	return o.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, _ devices.OperationArgs) (any, error) {
+		return nil, protocol.OutputOn(ctx, sess, on, duration)
+	}, opts)
+}
+
+func (o *Output) On(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	var duration time.Duration
+	if len(opts.Args) > 0 {
+		d, err := parseOutputDuration(opts.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		duration = d
+	}
+	return o.turnOn(ctx, opts, duration)
+}
+
+func (o *Output) Pulse(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	if len(opts.Args) == 0 {
+		return nil, fmt.Errorf("pulse requires a duration")
+	}
+	duration, err := parseOutputDuration(opts.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	if duration < time.Second {
+		return nil, fmt.Errorf("pulse duration must be at least 1s: %v", duration)
+	}
+	return o.turnOn(ctx, opts, duration)
+}
+
+func (o *Output) Off(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	on := o.DeviceConfigCustom.OutputNumber
+	return o.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, _ devices.OperationArgs) (any, error) {
+		return nil, protocol.OutputOff(ctx, sess, on)
+	}, opts)
+}
+
+func (o *Output) Toggle(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	on := o.DeviceConfigCustom.OutputNumber
+	return o.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, _ devices.OperationArgs) (any, error) {
+		return nil, protocol.OutputToggle(ctx, sess, on)
+	}, opts)
+}
+
+func (o *Output) state(ctx context.Context, opts devices.OperationArgs) (bool, error) {
+	ctx, sess, err := o.m1.session(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer sess.Release()
+	status, err := protocol.GetOutputStatusAll(ctx, sess)
+	if err != nil {
+		return false, err
+	}
+	on := o.DeviceConfigCustom.OutputNumber
+	if opts.Writer != nil {
+		_, _ = opts.Writer.Write(fmt.Appendf(nil, "output: %v, on %v", on, status[on-1]))
+	}
+	if o.logger != nil {
+		o.logger.Info("output-status", "output", on, "on", status[on-1])
+	}
+	return status[on-1], nil
+}
+
+func (o *Output) IsOn(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	on, err := o.state(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return nil, on, nil
+}
+
+func (o *Output) IsOff(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	on, err := o.state(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return nil, !on, nil
+}
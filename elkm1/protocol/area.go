@@ -0,0 +1,402 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+const NumAreas = 8
+
+// ArmingLevel represents the arming level requested by the a0..a: commands.
+type ArmingLevel byte
+
+const (
+	Disarm ArmingLevel = iota
+	ArmAway
+	ArmStay
+	ArmStayInstant
+	ArmNight
+	ArmNightInstant
+	ArmVacation
+	ArmNextAway  // M1 Ver. 4.2.8 or later.
+	ArmNextStay  // M1 Ver. 4.2.8 or later.
+	ForceArmAway // M1 Ver. 5.3.0 or later.
+	ForceArmStay // M1 Ver. 5.3.0 or later.
+)
+
+var (
+	armingLevelNames = []string{
+		"disarm",
+		"away",
+		"stay",
+		"stay-instant",
+		"night",
+		"night-instant",
+		"vacation",
+		"next-away",
+		"next-stay",
+		"force-away",
+		"force-stay",
+	}
+)
+
+// ArmingLevels returns all of the supported arming levels.
+func ArmingLevels() []ArmingLevel {
+	levels := make([]ArmingLevel, len(armingLevelNames))
+	for i := range levels {
+		levels[i] = ArmingLevel(i)
+	}
+	return levels
+}
+
+func (l ArmingLevel) String() string {
+	if int(l) >= len(armingLevelNames) {
+		return fmt.Sprintf("UnknownArmingLevel(%v)", int(l))
+	}
+	return armingLevelNames[l]
+}
+
+// UserCodeLen is the length of user codes in M1 requests, shorter
+// codes are padded with leading zeros.
+const UserCodeLen = 6
+
+// ArmRequest (a0..a:) arms or disarms an area using the specified user code.
+type ArmRequest struct {
+	Level ArmingLevel
+	Area  int
+	Code  string
+}
+
+func (m *ArmRequest) Type() string { return string([]byte{'a', '0' + byte(m.Level)}) }
+
+func (m *ArmRequest) Encode() []byte {
+	buf := appendDecInt(nil, m.Area, 1)
+	return append(buf, fmt.Sprintf("%06s", m.Code)...)
+}
+
+func (m *ArmRequest) Decode(data []byte) error {
+	if err := checkLen(m.Type(), data, 1+UserCodeLen); err != nil {
+		return err
+	}
+	area, data, err := readDecIntN(data, 1)
+	if err != nil {
+		return err
+	}
+	m.Area, m.Code = area, string(data)
+	return nil
+}
+
+func validateArea(area int) error {
+	if area < 1 || area > NumAreas {
+		return fmt.Errorf("invalid area number: %v", area)
+	}
+	return nil
+}
+
+func validateUserCode(code string) error {
+	if len(code) == 0 || len(code) > UserCodeLen {
+		return fmt.Errorf("user code must be 1 to %v digits long", UserCodeLen)
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return fmt.Errorf("user code must only contain digits")
+		}
+	}
+	return nil
+}
+
+// Arm arms or disarms the specified area. The M1 does not reply
+// to arming requests, use GetArmingStatus to determine the outcome.
+func Arm(ctx context.Context, sess *streamconn.Session, area int, level ArmingLevel, code string) error {
+	if err := validateArea(area); err != nil {
+		return err
+	}
+	if int(level) >= len(armingLevelNames) {
+		return fmt.Errorf("invalid arming level: %v", level)
+	}
+	if err := validateUserCode(code); err != nil {
+		return err
+	}
+	req := &ArmRequest{Level: level, Area: area, Code: code}
+	if err := checkSupported(ctx, req); err != nil {
+		return err
+	}
+	sess.SendSensitive(ctx, Encode(req))
+	return sess.Err()
+}
+
+// ArmedStatus represents the armed status of an area as reported by
+// an AS message.
+type ArmedStatus byte
+
+const (
+	Disarmed ArmedStatus = iota
+	ArmedAway
+	ArmedStay
+	ArmedStayInstant
+	ArmedNight
+	ArmedNightInstant
+	ArmedVacation
+)
+
+var (
+	armedStatusNames = []string{
+		"Disarmed",
+		"Armed Away",
+		"Armed Stay",
+		"Armed Stay Instant",
+		"Armed Night",
+		"Armed Night Instant",
+		"Armed Vacation",
+	}
+)
+
+func (a ArmedStatus) String() string {
+	if int(a) >= len(armedStatusNames) {
+		return fmt.Sprintf("UnknownArmedStatus(%v)", int(a))
+	}
+	return armedStatusNames[a]
+}
+
+// ArmUpState represents the arm up state of an area as reported by
+// an AS message.
+type ArmUpState byte
+
+const (
+	NotReadyToArm ArmUpState = iota
+	ReadyToArm
+	ReadyToForceArm
+	ArmedWithExitTimer
+	ArmedFully
+	ForceArmedWithViolatedZone
+	ArmedWithBypass
+)
+
+var (
+	armUpStateNames = []string{
+		"Not Ready To Arm",
+		"Ready To Arm",
+		"Ready To Force Arm",
+		"Armed With Exit Timer",
+		"Armed Fully",
+		"Force Armed With Violated Zone",
+		"Armed With Bypass",
+	}
+)
+
+func (a ArmUpState) String() string {
+	if int(a) >= len(armUpStateNames) {
+		return fmt.Sprintf("UnknownArmUpState(%v)", int(a))
+	}
+	return armUpStateNames[a]
+}
+
+// AlarmState represents the alarm state of an area as reported by
+// an AS message.
+type AlarmState byte
+
+const (
+	AreaNoAlarmActive AlarmState = iota
+	AreaEntranceDelayActive
+	AreaAlarmAbortDelayActive
+	AreaFireAlarm
+	AreaMedicalAlarm
+	AreaPoliceAlarm
+	AreaBurglarAlarm
+	AreaAux1Alarm
+	AreaAux2Alarm
+	AreaAux3Alarm // not used
+	AreaAux4Alarm // not used
+	AreaCarbonMonoxideAlarm
+	AreaEmergencyAlarm
+	AreaFreezeAlarm
+	AreaGasAlarm
+	AreaHeatAlarm
+	AreaWaterAlarm
+	AreaFireSupervisoryAlarm
+	AreaVerifyFireAlarm
+)
+
+var (
+	alarmStateNames = []string{
+		"No Alarm Active",
+		"Entrance Delay Active",
+		"Alarm Abort Delay Active",
+		"Fire Alarm",
+		"Medical Alarm",
+		"Police Alarm",
+		"Burglar Alarm",
+		"Aux1 Alarm",
+		"Aux2 Alarm",
+		"Aux3 Alarm",
+		"Aux4 Alarm",
+		"Carbon Monoxide Alarm",
+		"Emergency Alarm",
+		"Freeze Alarm",
+		"Gas Alarm",
+		"Heat Alarm",
+		"Water Alarm",
+		"Fire Supervisory",
+		"Verify Fire",
+	}
+)
+
+func (a AlarmState) String() string {
+	if int(a) >= len(alarmStateNames) {
+		return fmt.Sprintf("UnknownAlarmState(%v)", int(a))
+	}
+	return alarmStateNames[a]
+}
+
+// InAlarm returns true if the area is in full alarm, ie. not
+// in an entrance or abort delay.
+func (a AlarmState) InAlarm() bool {
+	return a >= AreaFireAlarm
+}
+
+// AreaStatus represents the arming status of a single area.
+type AreaStatus struct {
+	Armed ArmedStatus
+	ArmUp ArmUpState
+	Alarm AlarmState
+}
+
+// ArmingStatusRequest (as) requests the arming status of all areas.
+type ArmingStatusRequest struct{}
+
+func (m *ArmingStatusRequest) Type() string             { return "as" }
+func (m *ArmingStatusRequest) Encode() []byte           { return nil }
+func (m *ArmingStatusRequest) Decode(data []byte) error { return checkLen("as", data, 0) }
+
+// ArmingStatusReply (AS) is the reply to an ArmingStatusRequest, it is
+// also sent by the M1 whenever the arming status of an area changes.
+type ArmingStatusReply struct {
+	Areas [NumAreas]AreaStatus
+	// Timer is the first exit or entry time found, in seconds,
+	// M1 Ver. 4.11 and later.
+	Timer int
+}
+
+func (m *ArmingStatusReply) Type() string { return "AS" }
+
+func (m *ArmingStatusReply) Encode() []byte {
+	buf := make([]byte, 0, NumAreas*3+2)
+	for _, a := range m.Areas {
+		buf = append(buf, '0'+byte(a.Armed))
+	}
+	for _, a := range m.Areas {
+		buf = append(buf, '0'+byte(a.ArmUp))
+	}
+	for _, a := range m.Areas {
+		buf = append(buf, '0'+byte(a.Alarm))
+	}
+	return append(buf, hexLookup[(m.Timer>>4)&0x0f], hexLookup[m.Timer&0x0f])
+}
+
+func (m *ArmingStatusReply) Decode(data []byte) error {
+	if err := checkLen("arming status", data, NumAreas*3+2); err != nil {
+		return err
+	}
+	for i := range m.Areas {
+		m.Areas[i] = AreaStatus{
+			Armed: ArmedStatus(data[i] - '0'),
+			ArmUp: ArmUpState(data[NumAreas+i] - '0'),
+			Alarm: AlarmState(data[2*NumAreas+i] - '0'),
+		}
+	}
+	m.Timer, _ = readHexInt(data[NumAreas*3:])
+	return nil
+}
+
+// GetArmingStatus returns the arming status of all areas.
+func GetArmingStatus(ctx context.Context, sess *streamconn.Session) (ArmingStatusReply, error) {
+	var reply ArmingStatusReply
+	err := call(ctx, sess, &ArmingStatusRequest{}, &reply)
+	return reply, err
+}
+
+// EntryExitDelay distinguishes between the entry and exit timers reported
+// by EE messages.
+type EntryExitDelay byte
+
+const (
+	ExitDelay EntryExitDelay = iota
+	EntryDelay
+)
+
+var (
+	entryExitDelayNames = []string{
+		"exit",
+		"entry",
+	}
+)
+
+func (d EntryExitDelay) String() string {
+	if int(d) >= len(entryExitDelayNames) {
+		return fmt.Sprintf("UnknownEntryExitDelay(%v)", int(d))
+	}
+	return entryExitDelayNames[d]
+}
+
+// entryExitTimerLen is the length of an EE message, M1 versions prior to
+// 4.1.18 and 5.1.18 omit the trailing armed state.
+const entryExitTimerLen = 9
+
+// EntryExitTimer (EE) is sent by the M1 when an area's entry or exit
+// timers are started and again as each exit timer expires, M1 Ver.
+// 4.1.12, 5.1.12 and later.
+type EntryExitTimer struct {
+	Area  int
+	Delay EntryExitDelay
+	// Timer1 and Timer2 are the values, in seconds, of the two entry or
+	// exit timers when they were started.
+	Timer1, Timer2 int
+	// Armed is the arming state of the area, M1 Ver. 4.1.18, 5.1.18
+	// and later.
+	Armed ArmedStatus
+}
+
+func (m *EntryExitTimer) Type() string { return "EE" }
+
+func (m *EntryExitTimer) Encode() []byte {
+	buf := appendDecInt(nil, m.Area, 1)
+	buf = appendDecInt(buf, int(m.Delay), 1)
+	buf = appendDecInt(buf, m.Timer1, 3)
+	buf = appendDecInt(buf, m.Timer2, 3)
+	return append(buf, '0'+byte(m.Armed))
+}
+
+func (m *EntryExitTimer) Decode(data []byte) error {
+	if len(data) != entryExitTimerLen-1 {
+		if err := checkLen("entry/exit timer", data, entryExitTimerLen); err != nil {
+			return err
+		}
+	}
+	var delay int
+	var err error
+	for _, f := range []struct {
+		v *int
+		n int
+	}{
+		{&m.Area, 1},
+		{&delay, 1},
+		{&m.Timer1, 3},
+		{&m.Timer2, 3},
+	} {
+		if *f.v, data, err = readDecIntN(data, f.n); err != nil {
+			return fmt.Errorf("invalid entry/exit timer: %w", err)
+		}
+	}
+	m.Delay = EntryExitDelay(delay)
+	m.Armed = Disarmed
+	if len(data) > 0 {
+		m.Armed = ArmedStatus(data[0] - '0')
+	}
+	return nil
+}
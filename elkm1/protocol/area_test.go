@@ -0,0 +1,106 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestArm(t *testing.T) {
+	for _, tc := range []struct {
+		level protocol.ArmingLevel
+		area  int
+		code  string
+		msg   string
+	}{
+		{protocol.Disarm, 1, "3456", "0Da010034560038\r\n"},
+		{protocol.ArmAway, 1, "1234", "0Da11001234003F\r\n"},
+		{protocol.ArmStay, 3, "005678", "0Da23005678002C\r\n"},
+		{protocol.ArmVacation, 8, "5678", "0Da680056780023\r\n"},
+		{protocol.ForceArmStay, 1, "1234", "0Da:10012340036\r\n"},
+	} {
+		req := &protocol.ArmRequest{Level: tc.level, Area: tc.area, Code: tc.code}
+		if got, want := string(protocol.Encode(req)), tc.msg; got != want {
+			t.Errorf("%v: got %q, want %q", tc.level, got, want)
+		}
+		m, err := protocol.Decode([]byte(tc.msg))
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tc.msg, err)
+		}
+		if got, want := m.(*protocol.ArmRequest).Area, tc.area; got != want {
+			t.Errorf("%v: got %v, want %v", tc.msg, got, want)
+		}
+	}
+
+	_, sess := newSession()
+	defer sess.Release()
+	ctx := context.Background()
+	if err := protocol.Arm(ctx, sess, 9, protocol.ArmAway, "1234"); err == nil {
+		t.Errorf("expected an error for an invalid area")
+	}
+	if err := protocol.Arm(ctx, sess, 1, protocol.ArmAway, "12a4"); err == nil {
+		t.Errorf("expected an error for an invalid user code")
+	}
+}
+
+func TestArmingStatus(t *testing.T) {
+	msg := "1EAS1000000031111111000000000902\r\n"
+	m, err := protocol.Decode([]byte(msg))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	as := m.(*protocol.ArmingStatusReply)
+	if got, want := as.Areas[0], (protocol.AreaStatus{
+		Armed: protocol.ArmedAway,
+		ArmUp: protocol.ArmedWithExitTimer,
+		Alarm: protocol.AreaNoAlarmActive,
+	}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := as.Areas[1], (protocol.AreaStatus{
+		Armed: protocol.Disarmed,
+		ArmUp: protocol.ReadyToArm,
+	}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := as.Timer, 9; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := string(protocol.Encode(as)), msg; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	m, err = protocol.Decode([]byte("1EAS100000004000000030000000000E\r\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	as = m.(*protocol.ArmingStatusReply)
+	if got, want := as.Areas[0].Alarm, protocol.AreaFireAlarm; got != want || !got.InAlarm() {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestEntryExitTimer(t *testing.T) {
+	for _, tc := range []struct {
+		msg     string
+		decoded *protocol.EntryExitTimer
+	}{
+		{"0FEE10060120100E5\r\n", &protocol.EntryExitTimer{
+			Area: 1, Delay: protocol.ExitDelay, Timer1: 60, Timer2: 120, Armed: protocol.ArmedAway}},
+		{"0FEE21030254200DD\r\n", &protocol.EntryExitTimer{
+			Area: 2, Delay: protocol.EntryDelay, Timer1: 30, Timer2: 254, Armed: protocol.ArmedStay}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	// Older firmware versions do not send the armed state.
+	decodes(t, "0EEE31000000001D\r\n", &protocol.EntryExitTimer{Area: 3, Delay: protocol.EntryDelay})
+	if got, want := protocol.EntryDelay.String(), "entry"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
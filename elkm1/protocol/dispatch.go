@@ -0,0 +1,456 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"cloudeng.io/logging/ctxlog"
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+// Frame represents a single decoded M1 message, ie. its type, subtype and
+// the data between the subtype and the reserved bytes.
+type Frame struct {
+	Type, SubType byte
+	Data          []byte
+}
+
+// Handler is called for every frame that is not the reply to an outstanding
+// request. Handlers that need to respond to a message, eg. to acknowledge
+// an AR alarm report, may use Reply to do so.
+type Handler func(ctx context.Context, f Frame)
+
+// Dispatcher demultiplexes the messages read from an M1 connection: replies
+// are returned to the waiting request and all other messages, such as ZC
+// zone changes, AS arming updates, EE entry/exit timers and XK heartbeats,
+// are delivered to the registered handlers.
+//
+// Start starts a reader that owns the read side of a connection so that
+// unsolicited messages are delivered as soon as they are received. Without
+// a reader, messages are only read, and hence dispatched, whilst a request
+// is waiting for its reply or Listen is running.
+type Dispatcher struct {
+	mu       sync.Mutex
+	nextID   int
+	handlers map[[2]byte][]registered
+	all      []registered
+	waiters  map[[2]byte][]*waiter
+	reader   *reader
+}
+
+type registered struct {
+	id int
+	h  Handler
+}
+
+// NewDispatcher returns a new Dispatcher with no registered handlers.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		handlers: map[[2]byte][]registered{},
+		waiters:  map[[2]byte][]*waiter{},
+	}
+}
+
+// Handle registers a handler for messages of the specified type and subtype,
+// eg. 'Z', 'C' for zone change updates. The returned function removes the
+// handler.
+func (d *Dispatcher) Handle(typ, subtype byte, h Handler) func() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	k := [2]byte{typ, subtype}
+	d.nextID++
+	id := d.nextID
+	d.handlers[k] = append(d.handlers[k], registered{id: id, h: h})
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.handlers[k] = removeHandler(d.handlers[k], id)
+	}
+}
+
+// HandleAll registers a handler that is called for every unsolicited message.
+// The returned function removes the handler.
+func (d *Dispatcher) HandleAll(h Handler) func() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextID++
+	id := d.nextID
+	d.all = append(d.all, registered{id: id, h: h})
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.all = removeHandler(d.all, id)
+	}
+}
+
+func removeHandler(hs []registered, id int) []registered {
+	for i, r := range hs {
+		if r.id == id {
+			return append(hs[:i:i], hs[i+1:]...)
+		}
+	}
+	return hs
+}
+
+func (d *Dispatcher) handlersFor(typ, subtype byte) []Handler {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	specific := d.handlers[[2]byte{typ, subtype}]
+	hs := make([]Handler, 0, len(d.all)+len(specific))
+	for _, r := range specific {
+		hs = append(hs, r.h)
+	}
+	for _, r := range d.all {
+		hs = append(hs, r.h)
+	}
+	return hs
+}
+
+// Dispatch decodes msg and delivers it to the registered handlers. Messages
+// that cannot be decoded are logged and discarded.
+func (d *Dispatcher) Dispatch(ctx context.Context, msg []byte) {
+	if d == nil {
+		return
+	}
+	var resp Response
+	typ, subtype, data, err := resp.Decode(msg)
+	if err != nil {
+		ctxlog.Info(ctx, "elk-m1: discarding undecodable message", "msg", string(msg), "err", err)
+		return
+	}
+	d.Deliver(ctx, Frame{Type: typ, SubType: subtype, Data: data})
+}
+
+// Deliver delivers an already decoded frame to the registered handlers.
+func (d *Dispatcher) Deliver(ctx context.Context, f Frame) {
+	if d == nil {
+		return
+	}
+	for _, h := range d.handlersFor(f.Type, f.SubType) {
+		h(ctx, f)
+	}
+}
+
+// RPC sends req and waits for the reply described by resp. All other
+// messages read whilst waiting for the reply are dispatched to the
+// registered handlers.
+func (d *Dispatcher) RPC(ctx context.Context, sess *streamconn.Session, req []byte, resp Response) ([]byte, error) {
+	return d.call(ctx, sess, resp, func() { sess.Send(ctx, req) })
+}
+
+// call uses send to send a request and then waits for the reply described
+// by resp. The reply is returned by the reader if one is running, the
+// waiter is registered before the request is sent so that a prompt reply
+// cannot be mistaken for an unsolicited message.
+func (d *Dispatcher) call(ctx context.Context, sess *streamconn.Session, resp Response, send func()) ([]byte, error) {
+	w, timeout := d.expect(resp)
+	send()
+	if w == nil {
+		return d.readUntil(ctx, sess, resp)
+	}
+	if err := sess.Err(); err != nil {
+		d.cancel(w)
+		return nil, err
+	}
+	return d.await(ctx, w, timeout)
+}
+
+// WaitFor waits for the reply described by resp. If a reader is running
+// the reply is returned by it, otherwise messages are read from sess until
+// the reply is received and all other messages are dispatched to the
+// registered handlers.
+func (d *Dispatcher) WaitFor(ctx context.Context, sess *streamconn.Session, resp Response) ([]byte, error) {
+	if w, timeout := d.expect(resp); w != nil {
+		return d.await(ctx, w, timeout)
+	}
+	return d.readUntil(ctx, sess, resp)
+}
+
+func (d *Dispatcher) readUntil(ctx context.Context, sess *streamconn.Session, resp Response) ([]byte, error) {
+	ctx = context.WithValue(ctx, replierKey{}, sessionReplier{sess})
+	for {
+		msg, err := sess.ReadUntil(ctx, "\r\n")
+		if err != nil {
+			return nil, err
+		}
+		ok, err := resp.IsExpected(msg)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return resp.Expected(msg)
+		}
+		d.Dispatch(ctx, msg)
+	}
+}
+
+// Listen dispatches messages until the context is canceled or a read
+// fails. If a reader is running then Listen waits for it to stop, otherwise
+// it reads messages from sess, in which case the transport's read timeout
+// expiring also ends Listen.
+func (d *Dispatcher) Listen(ctx context.Context, sess *streamconn.Session) error {
+	if r := d.running(); r != nil {
+		return r.wait(ctx)
+	}
+	ctx = context.WithValue(ctx, replierKey{}, sessionReplier{sess})
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		msg, err := sess.ReadUntil(ctx, "\r\n")
+		if err != nil {
+			return err
+		}
+		d.Dispatch(ctx, msg)
+	}
+}
+
+// Wait waits until the context is canceled or the running reader stops,
+// handlers are called by the reader in the meantime. Unlike Listen, it
+// does not require a session and so does not prevent other requests from
+// being made whilst waiting. An error is returned if no reader is running.
+func (d *Dispatcher) Wait(ctx context.Context) error {
+	r := d.running()
+	if r == nil {
+		return fmt.Errorf("no reader is running")
+	}
+	return r.wait(ctx)
+}
+
+// reader represents a reader started by Start.
+type reader struct {
+	timeout time.Duration
+	done    chan struct{}
+	err     error
+}
+
+func (r *reader) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.done:
+		return r.err
+	}
+}
+
+type waiter struct {
+	key [2]byte
+	ch  chan result
+}
+
+type result struct {
+	data []byte
+	err  error
+}
+
+// Start starts a goroutine that reads every message sent on t until ctx
+// is canceled or a read fails for any reason other than the transport's
+// read timeout expiring, eg. because t has been closed. Replies are
+// returned to the request waiting for them, which waits at most timeout
+// for its reply, and all other messages are dispatched to the registered
+// handlers as they are received. Start should be called once for every new
+// connection, the reader for any previous connection is replaced. The
+// returned channel is sent the error that stopped the reader.
+func (d *Dispatcher) Start(ctx context.Context, t streamconn.Transport, timeout time.Duration) <-chan error {
+	r := &reader{timeout: timeout, done: make(chan struct{})}
+	d.mu.Lock()
+	d.reader = r
+	d.mu.Unlock()
+	errCh := make(chan error, 1)
+	go func() {
+		err := d.read(ctx, t)
+		ctxlog.Info(ctx, "elk-m1: reader stopped", "err", err)
+		d.stopped(r, err)
+		errCh <- err
+	}()
+	return errCh
+}
+
+// stopped records that r has stopped and fails all outstanding requests
+// if r is the current reader.
+func (d *Dispatcher) stopped(r *reader, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r.err = err
+	close(r.done)
+	if d.reader != r {
+		return
+	}
+	d.reader = nil
+	for k, ws := range d.waiters {
+		for _, w := range ws {
+			w.ch <- result{err: err}
+		}
+		delete(d.waiters, k)
+	}
+}
+
+func (d *Dispatcher) read(ctx context.Context, t streamconn.Transport) error {
+	ctx = context.WithValue(ctx, replierKey{}, transportReplier{t})
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		// A message that is only partially received when the read
+		// timeout expires is lost, but the M1 sends complete messages
+		// so this is not expected in practice.
+		msg, err := t.ReadUntil(ctx, []string{"\r\n"})
+		if err != nil {
+			if isTimeout(err) && ctx.Err() == nil {
+				continue
+			}
+			return err
+		}
+		var resp Response
+		typ, subtype, data, err := resp.Decode(msg)
+		if err != nil {
+			ctxlog.Info(ctx, "elk-m1: discarding undecodable message", "msg", string(msg), "err", err)
+			continue
+		}
+		if d.reply([2]byte{typ, subtype}, data) {
+			continue
+		}
+		d.Deliver(ctx, Frame{Type: typ, SubType: subtype, Data: data})
+	}
+}
+
+// isTimeout returns true if err is the result of a read timeout or
+// deadline expiring.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+func (d *Dispatcher) running() *reader {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.reader
+}
+
+// expect registers a waiter for the reply described by resp if a reader
+// is running, it returns nil otherwise.
+func (d *Dispatcher) expect(resp Response) (*waiter, time.Duration) {
+	if d == nil {
+		return nil, 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.reader == nil {
+		return nil, 0
+	}
+	w := &waiter{key: [2]byte{resp.Type, resp.SubType}, ch: make(chan result, 1)}
+	d.waiters[w.key] = append(d.waiters[w.key], w)
+	return w, d.reader.timeout
+}
+
+// reply returns data to the oldest waiter for messages of type k, if any.
+func (d *Dispatcher) reply(k [2]byte, data []byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ws := d.waiters[k]
+	if len(ws) == 0 {
+		return false
+	}
+	d.waiters[k] = ws[1:]
+	ws[0].ch <- result{data: data}
+	return true
+}
+
+func (d *Dispatcher) cancel(w *waiter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ws := d.waiters[w.key]
+	for i, o := range ws {
+		if o == w {
+			d.waiters[w.key] = append(ws[:i:i], ws[i+1:]...)
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) await(ctx context.Context, w *waiter, timeout time.Duration) ([]byte, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case r := <-w.ch:
+		return r.data, r.err
+	case <-ctx.Done():
+		d.cancel(w)
+		return nil, ctx.Err()
+	case <-expired:
+		d.cancel(w)
+		return nil, fmt.Errorf("no '%c%c' reply received within %v: %w", w.key[0], w.key[1], timeout, os.ErrDeadlineExceeded)
+	}
+}
+
+type replier interface {
+	reply(ctx context.Context, msg []byte) error
+}
+
+type sessionReplier struct {
+	sess *streamconn.Session
+}
+
+func (r sessionReplier) reply(ctx context.Context, msg []byte) error {
+	r.sess.Send(ctx, msg)
+	return r.sess.Err()
+}
+
+type transportReplier struct {
+	t streamconn.Transport
+}
+
+func (r transportReplier) reply(ctx context.Context, msg []byte) error {
+	_, err := r.t.Send(ctx, msg)
+	return err
+}
+
+type replierKey struct{}
+
+// Reply sends m on the connection that the message being delivered to a
+// handler was read from, it may only be called from within a handler.
+func Reply(ctx context.Context, m Message) error {
+	r, ok := ctx.Value(replierKey{}).(replier)
+	if !ok {
+		return fmt.Errorf("%v: no connection to reply on", m.Type())
+	}
+	return r.reply(ctx, Encode(m))
+}
+
+type dispatcherKey struct{}
+
+// ContextWithDispatcher returns a context that carries the specified
+// dispatcher. All of the request functions in this package use the
+// dispatcher in their context, if any, to deliver unsolicited messages.
+func ContextWithDispatcher(ctx context.Context, d *Dispatcher) context.Context {
+	return context.WithValue(ctx, dispatcherKey{}, d)
+}
+
+// DispatcherFromContext returns the dispatcher stored in ctx, or nil.
+func DispatcherFromContext(ctx context.Context) *Dispatcher {
+	d, _ := ctx.Value(dispatcherKey{}).(*Dispatcher)
+	return d
+}
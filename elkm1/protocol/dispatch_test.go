@@ -0,0 +1,280 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+type noIdle struct{}
+
+func (noIdle) Reset(context.Context) {}
+
+// fakeTransport returns the lines it is created with, one per ReadUntil
+// call, and records everything sent to it.
+type fakeTransport struct {
+	lines []string
+	sent  []string
+}
+
+func (ft *fakeTransport) Send(_ context.Context, buf []byte) (int, error) {
+	ft.sent = append(ft.sent, string(buf))
+	return len(buf), nil
+}
+
+func (ft *fakeTransport) SendSensitive(ctx context.Context, buf []byte) (int, error) {
+	return ft.Send(ctx, buf)
+}
+
+func (ft *fakeTransport) ReadUntil(_ context.Context, _ []string) ([]byte, error) {
+	if len(ft.lines) == 0 {
+		return nil, io.EOF
+	}
+	l := ft.lines[0]
+	ft.lines = ft.lines[1:]
+	return []byte(l), nil
+}
+
+func (ft *fakeTransport) Close(context.Context) error {
+	return nil
+}
+
+func newSession(lines ...string) (*fakeTransport, *streamconn.Session) {
+	ft := &fakeTransport{lines: lines}
+	var mgr streamconn.SessionManager
+	return ft, mgr.New(ft, noIdle{})
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	ft, sess := newSession(
+		"16XK2636115020605110006F\r\n",
+		"0AZC002900C7\r\n",
+		"16RR0059107251205110006E\r\n",
+	)
+	defer sess.Release()
+
+	d := protocol.NewDispatcher()
+	var zc, all []string
+	d.Handle('Z', 'C', func(_ context.Context, f protocol.Frame) {
+		zc = append(zc, string(f.Data))
+	})
+	d.HandleAll(func(_ context.Context, f protocol.Frame) {
+		all = append(all, string([]byte{f.Type, f.SubType}))
+	})
+
+	var req protocol.Request
+	msg, resp := req.RealTime()
+	data, err := d.RPC(ctx, sess, msg, resp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := string(data), "0059107251205110"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := strings.Join(ft.sent, ""), string(msg); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := strings.Join(zc, ","), "0029"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := strings.Join(all, ","), "XK,ZC"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDispatcherListen(t *testing.T) {
+	ctx := context.Background()
+	_, sess := newSession(
+		"0AZC002900C7\r\n",
+		"00XX\r\n",
+		"0AZC003200CD\r\n",
+	)
+	defer sess.Release()
+	d := protocol.NewDispatcher()
+	var zc []string
+	d.Handle('Z', 'C', func(_ context.Context, f protocol.Frame) {
+		zc = append(zc, string(f.Data))
+	})
+	if err := d.Listen(ctx, sess); err != io.EOF {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := strings.Join(zc, ","), "0029,0032"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// rpc with no dispatcher in the context discards unsolicited messages.
+	_, sess2 := newSession("0AZC002900C7\r\n", "16RR0059107251205110006E\r\n")
+	defer sess2.Release()
+	ctx = protocol.ContextWithDispatcher(ctx, nil)
+	if _, _, err := protocol.GetTime(ctx, sess2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// pipeTransport returns the lines written to its lines channel, blocking
+// until one is available, and calls onSend, if set, for every message sent.
+type pipeTransport struct {
+	lines  chan string
+	mu     sync.Mutex
+	sent   []string
+	onSend func(string)
+}
+
+func newPipeTransport() *pipeTransport {
+	return &pipeTransport{lines: make(chan string, 10)}
+}
+
+func (pt *pipeTransport) Send(_ context.Context, buf []byte) (int, error) {
+	pt.mu.Lock()
+	pt.sent = append(pt.sent, string(buf))
+	onSend := pt.onSend
+	pt.mu.Unlock()
+	if onSend != nil {
+		onSend(string(buf))
+	}
+	return len(buf), nil
+}
+
+func (pt *pipeTransport) SendSensitive(ctx context.Context, buf []byte) (int, error) {
+	return pt.Send(ctx, buf)
+}
+
+func (pt *pipeTransport) ReadUntil(ctx context.Context, _ []string) ([]byte, error) {
+	select {
+	case l, ok := <-pt.lines:
+		if !ok {
+			return nil, io.EOF
+		}
+		return []byte(l), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (pt *pipeTransport) Close(context.Context) error {
+	return nil
+}
+
+func (pt *pipeTransport) getSent() []string {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return slices.Clone(pt.sent)
+}
+
+func TestDispatcherReader(t *testing.T) {
+	ctx := context.Background()
+	pt := newPipeTransport()
+	d := protocol.NewDispatcher()
+	zc := make(chan string, 10)
+	d.Handle('Z', 'C', func(_ context.Context, f protocol.Frame) {
+		zc <- string(f.Data)
+	})
+	var mu sync.Mutex
+	var all []string
+	remove := d.HandleAll(func(_ context.Context, f protocol.Frame) {
+		mu.Lock()
+		defer mu.Unlock()
+		all = append(all, string([]byte{f.Type, f.SubType}))
+	})
+	if err := d.Wait(ctx); err == nil {
+		t.Errorf("expected an error since no reader is running")
+	}
+	errCh := d.Start(ctx, pt, time.Minute)
+
+	// An unsolicited message is delivered with no request outstanding.
+	pt.lines <- "0AZC002900C7\r\n"
+	select {
+	case got := <-zc:
+		if want := "0029"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for an unsolicited message")
+	}
+
+	// Replies are returned to the waiting request, other messages that
+	// arrive before the reply are dispatched.
+	pt.onSend = func(string) {
+		pt.lines <- "16XK2636115020605110006F\r\n"
+		pt.lines <- "16RR0059107251205110006E\r\n"
+	}
+	var mgr streamconn.SessionManager
+	sess := mgr.New(pt, noIdle{})
+	ctx = protocol.ContextWithDispatcher(ctx, d)
+	tm, _, err := protocol.GetTime(ctx, sess)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := tm.Year(), 2005; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := strings.Join(pt.getSent(), ""), "06rr0056\r\n"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	mu.Lock()
+	if got, want := strings.Join(all, ","), "ZC,XK"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	mu.Unlock()
+	remove()
+
+	// Listen waits for the reader to stop and requests that are waiting
+	// when it stops fail.
+	pt.onSend = func(string) { close(pt.lines) }
+	if _, _, err := protocol.GetTime(ctx, sess); err != io.EOF {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := d.Listen(ctx, sess); err != io.EOF {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := <-errCh; err != io.EOF {
+		t.Errorf("unexpected error: %v", err)
+	}
+	sess.Release()
+	mu.Lock()
+	if got, want := len(all), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	mu.Unlock()
+}
+
+func TestDispatcherReaderTimeout(t *testing.T) {
+	ctx := context.Background()
+	pt := newPipeTransport()
+	d := protocol.NewDispatcher()
+	d.Start(ctx, pt, 10*time.Millisecond)
+	defer close(pt.lines)
+	var mgr streamconn.SessionManager
+	sess := mgr.New(pt, noIdle{})
+	defer sess.Release()
+	ctx = protocol.ContextWithDispatcher(ctx, d)
+	if _, _, err := protocol.GetTime(ctx, sess); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// A late reply is treated as an unsolicited message.
+	rr := make(chan struct{})
+	d.Handle('R', 'R', func(context.Context, protocol.Frame) { close(rr) })
+	pt.lines <- "16RR0059107251205110006E\r\n"
+	<-rr
+
+	// Wait does not need a session.
+	wctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := d.Wait(wctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,594 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+const (
+	// DisplayLineLen is the number of characters on each line of a
+	// keypad display message.
+	DisplayLineLen = 16
+	// MaxDisplayTime is the maximum time, in seconds, that a display
+	// message can be displayed for.
+	MaxDisplayTime = 65535
+)
+
+// displayLineEnd marks the end of a display message line that is shorter
+// than DisplayLineLen, the remaining characters are ignored.
+const displayLineEnd = '^'
+
+// DisplayClear determines how a keypad display message is cleared.
+type DisplayClear byte
+
+const (
+	DisplayClearNow         DisplayClear = iota // Clear any currently displayed message.
+	DisplayUntilAcknowledge                     // Display until the * key is pressed.
+	DisplayUntilTimeout                         // Display until the display time expires.
+)
+
+var (
+	displayClearNames = []string{
+		"clear",
+		"acknowledge",
+		"timeout",
+	}
+)
+
+func (c DisplayClear) String() string {
+	if int(c) >= len(displayClearNames) {
+		return fmt.Sprintf("UnknownDisplayClear(%v)", int(c))
+	}
+	return displayClearNames[c]
+}
+
+// ParseDisplayClear parses the name of a display clear option.
+func ParseDisplayClear(name string) (DisplayClear, error) {
+	for i, n := range displayClearNames {
+		if n == name {
+			return DisplayClear(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown display clear option: %q", name)
+}
+
+// DisplayMessageRequest (dm) displays a message, of up to two lines, on
+// the keypads in an area. The second line, if any, is alternately scrolled
+// with the first. The M1 does not reply to dm requests.
+type DisplayMessageRequest struct {
+	Area  int
+	Clear DisplayClear
+	Beep  bool
+	// Seconds is the time that the message is displayed for, zero
+	// for no timeout.
+	Seconds int
+	Line1   string
+	Line2   string
+}
+
+func (m *DisplayMessageRequest) Type() string { return "dm" }
+
+func appendDisplayLine(buf []byte, line string) []byte {
+	buf = append(buf, line...)
+	if len(line) < DisplayLineLen {
+		buf = append(buf, displayLineEnd)
+		buf = append(buf, bytes.Repeat([]byte{' '}, DisplayLineLen-len(line)-1)...)
+	}
+	return buf
+}
+
+func readDisplayLine(data []byte) string {
+	if i := bytes.IndexByte(data, displayLineEnd); i >= 0 {
+		data = data[:i]
+	}
+	return string(data)
+}
+
+func (m *DisplayMessageRequest) Encode() []byte {
+	buf := appendDecInt(nil, m.Area, 1)
+	buf = appendDecInt(buf, int(m.Clear), 1)
+	buf = append(buf, boolDigit(m.Beep))
+	buf = appendDecInt(buf, m.Seconds, 5)
+	buf = appendDisplayLine(buf, m.Line1)
+	return appendDisplayLine(buf, m.Line2)
+}
+
+func (m *DisplayMessageRequest) Decode(data []byte) error {
+	if err := checkLen("dm", data, 8+2*DisplayLineLen); err != nil {
+		return err
+	}
+	area, data, err := readDecIntN(data, 1)
+	if err != nil {
+		return err
+	}
+	mode, data, err := readDecIntN(data, 1)
+	if err != nil {
+		return err
+	}
+	beep, data, err := readDecIntN(data, 1)
+	if err != nil {
+		return err
+	}
+	seconds, data, err := readDecIntN(data, 5)
+	if err != nil {
+		return err
+	}
+	m.Area, m.Clear, m.Beep, m.Seconds = area, DisplayClear(mode), beep == 1, seconds
+	m.Line1 = readDisplayLine(data[:DisplayLineLen])
+	m.Line2 = readDisplayLine(data[DisplayLineLen:])
+	return nil
+}
+
+func validateDisplayLine(line string) error {
+	if len(line) > DisplayLineLen {
+		return fmt.Errorf("display line is longer than %v characters: %q", DisplayLineLen, line)
+	}
+	for _, c := range []byte(line) {
+		if c < ' ' || c > '~' || c == displayLineEnd {
+			return fmt.Errorf("display line contains an invalid character: %q", line)
+		}
+	}
+	return nil
+}
+
+// DisplayMessage displays the message specified by req on the keypads
+// in req.Area.
+func DisplayMessage(ctx context.Context, sess *streamconn.Session, req DisplayMessageRequest) error {
+	if err := validateArea(req.Area); err != nil {
+		return err
+	}
+	if int(req.Clear) >= len(displayClearNames) {
+		return fmt.Errorf("invalid display clear option: %v", req.Clear)
+	}
+	if req.Seconds < 0 || req.Seconds > MaxDisplayTime {
+		return fmt.Errorf("invalid display time: %v, must be 0 to %v seconds", req.Seconds, MaxDisplayTime)
+	}
+	if err := validateDisplayLine(req.Line1); err != nil {
+		return err
+	}
+	if err := validateDisplayLine(req.Line2); err != nil {
+		return err
+	}
+	return send(ctx, sess, &req)
+}
+
+// ClearDisplayMessage clears any message displayed on the keypads in
+// the specified area.
+func ClearDisplayMessage(ctx context.Context, sess *streamconn.Session, area int) error {
+	return DisplayMessage(ctx, sess, DisplayMessageRequest{Area: area, Clear: DisplayClearNow})
+}
+
+const NumKeypads = 16
+
+// KeypadAreasRequest (ka) requests the areas that all keypads are
+// assigned to.
+type KeypadAreasRequest struct{}
+
+func (m *KeypadAreasRequest) Type() string             { return "ka" }
+func (m *KeypadAreasRequest) Encode() []byte           { return nil }
+func (m *KeypadAreasRequest) Decode(data []byte) error { return checkLen("ka", data, 0) }
+
+// KeypadAreasReply (KA) is the reply to a KeypadAreasRequest.
+type KeypadAreasReply struct {
+	// Areas is indexed by keypad number minus one.
+	Areas [NumKeypads]int
+}
+
+func (m *KeypadAreasReply) Type() string { return "KA" }
+
+func (m *KeypadAreasReply) Encode() []byte {
+	buf := make([]byte, 0, NumKeypads)
+	for _, a := range m.Areas {
+		buf = append(buf, '0'+byte(a))
+	}
+	return buf
+}
+
+func (m *KeypadAreasReply) Decode(data []byte) error {
+	if err := checkLen("keypad areas", data, NumKeypads); err != nil {
+		return err
+	}
+	for i := range m.Areas {
+		m.Areas[i] = int(data[i] - '0')
+	}
+	return nil
+}
+
+// GetKeypadAreas returns the areas that all keypads are assigned to.
+func GetKeypadAreas(ctx context.Context, sess *streamconn.Session) (KeypadAreasReply, error) {
+	var reply KeypadAreasReply
+	err := call(ctx, sess, &KeypadAreasRequest{}, &reply)
+	return reply, err
+}
+
+// KeypadKey represents the key numbers reported in KC messages.
+type KeypadKey byte
+
+const (
+	NoKey       KeypadKey = 0 // No key, or a user code was entered.
+	StarKey     KeypadKey = 11
+	PoundKey    KeypadKey = 12
+	F1Key       KeypadKey = 13
+	F2Key       KeypadKey = 14
+	F3Key       KeypadKey = 15
+	F4Key       KeypadKey = 16
+	StayKey     KeypadKey = 17
+	ExitKey     KeypadKey = 18
+	ChimeKey    KeypadKey = 19
+	BypassKey   KeypadKey = 20
+	ElkKey      KeypadKey = 21
+	DownKey     KeypadKey = 22
+	UpKey       KeypadKey = 23
+	RightKey    KeypadKey = 24
+	LeftKey     KeypadKey = 25
+	F6Key       KeypadKey = 26
+	F5Key       KeypadKey = 27
+	DataKeyMode KeypadKey = 28 // Data was entered, acts as a carriage return.
+)
+
+var (
+	keypadKeyNames = map[KeypadKey]string{
+		NoKey:       "none",
+		StarKey:     "*",
+		PoundKey:    "#",
+		F1Key:       "f1",
+		F2Key:       "f2",
+		F3Key:       "f3",
+		F4Key:       "f4",
+		StayKey:     "stay",
+		ExitKey:     "exit",
+		ChimeKey:    "chime",
+		BypassKey:   "bypass",
+		ElkKey:      "elk",
+		DownKey:     "down",
+		UpKey:       "up",
+		RightKey:    "right",
+		LeftKey:     "left",
+		F6Key:       "f6",
+		F5Key:       "f5",
+		DataKeyMode: "data-entered",
+	}
+)
+
+func (k KeypadKey) String() string {
+	if n, ok := keypadKeyNames[k]; ok {
+		return n
+	}
+	return fmt.Sprintf("UnknownKeypadKey(%v)", int(k))
+}
+
+// NumFunctionKeys is the number of function keys, F1 to F6, on a keypad.
+const NumFunctionKeys = 6
+
+// Illumination represents the illumination status of a keypad function key.
+type Illumination byte
+
+const (
+	IlluminationOff Illumination = iota
+	IlluminationOn
+	IlluminationBlinking
+)
+
+var (
+	illuminationNames = []string{
+		"off",
+		"on",
+		"blinking",
+	}
+)
+
+func (i Illumination) String() string {
+	if int(i) >= len(illuminationNames) {
+		return fmt.Sprintf("UnknownIllumination(%v)", int(i))
+	}
+	return illuminationNames[i]
+}
+
+// BeepChime is a bitmask of the beep and chime state of an area as
+// reported in KC messages.
+type BeepChime byte
+
+const (
+	SingleBeep   BeepChime = 1 << iota
+	ConstantBeep           // Set whilst the keypads are constantly beeping.
+	Chime
+	Voice
+)
+
+var (
+	beepChimeNames = []string{
+		"single-beep",
+		"constant-beep",
+		"chime",
+		"voice",
+	}
+)
+
+func (b BeepChime) String() string {
+	if b == 0 {
+		return "off"
+	}
+	var names []string
+	for i, n := range beepChimeNames {
+		if b&(1<<i) != 0 {
+			names = append(names, n)
+		}
+	}
+	if b>>len(beepChimeNames) != 0 {
+		names = append(names, fmt.Sprintf("UnknownBeepChime(%#x)", byte(b)))
+	}
+	return strings.Join(names, "+")
+}
+
+// Beeping returns true if the keypads are beeping.
+func (b BeepChime) Beeping() bool {
+	return b&(SingleBeep|ConstantBeep) != 0
+}
+
+// The lengths of the data in KC messages for the various M1 versions.
+const (
+	keypadChangeLen     = 4  // Prior to M1 Ver. 4.2.8.
+	keypadChangeLEDLen  = 11 // Prior to M1 Ver. 4.3.2.
+	keypadChangeFullLen = 19
+)
+
+// KeypadChange (KC) is sent by the M1, if so configured, whenever a key
+// is pressed on a keypad, and in reply to a KeypadStatusRequest.
+type KeypadChange struct {
+	Keypad int
+	Key    KeypadKey
+	// Illumination is the illumination status of F1 to F6, M1 Ver. 4.2.8
+	// and later.
+	Illumination [NumFunctionKeys]Illumination
+	// CodeRequiredToBypass is true if a user code is required to bypass
+	// zones, M1 Ver. 4.2.8 and later.
+	CodeRequiredToBypass bool
+	// BeepChime is the beep and chime state of each area, M1 Ver. 4.3.2
+	// and later.
+	BeepChime [NumAreas]BeepChime
+}
+
+func (m *KeypadChange) Type() string { return "KC" }
+
+func (m *KeypadChange) Encode() []byte {
+	buf := appendDecInt(nil, m.Keypad, 2)
+	buf = appendDecInt(buf, int(m.Key), 2)
+	for _, i := range m.Illumination {
+		buf = append(buf, '0'+byte(i))
+	}
+	buf = append(buf, boolDigit(m.CodeRequiredToBypass))
+	for _, b := range m.BeepChime {
+		buf = append(buf, '0'+byte(b))
+	}
+	return buf
+}
+
+func (m *KeypadChange) Decode(data []byte) error {
+	switch len(data) {
+	case keypadChangeLen, keypadChangeLEDLen, keypadChangeFullLen:
+	default:
+		return fmt.Errorf("unexpected response size for keypad change: got %v, expected %v, %v or %v", len(data), keypadChangeLen, keypadChangeLEDLen, keypadChangeFullLen)
+	}
+	*m = KeypadChange{}
+	keypad, rest, err := readDecIntN(data, 2)
+	if err != nil {
+		return err
+	}
+	key, rest, err := readDecIntN(rest, 2)
+	if err != nil {
+		return err
+	}
+	m.Keypad, m.Key = keypad, KeypadKey(key)
+	if len(rest) == 0 {
+		return nil
+	}
+	for i := range m.Illumination {
+		m.Illumination[i] = Illumination(rest[i] - '0')
+	}
+	m.CodeRequiredToBypass = rest[NumFunctionKeys] == '1'
+	rest = rest[NumFunctionKeys+1:]
+	if len(rest) == 0 {
+		return nil
+	}
+	for i := range m.BeepChime {
+		m.BeepChime[i] = BeepChime(rest[i] - '0')
+	}
+	return nil
+}
+
+// KeypadStatusRequest (kc) requests the function key illumination status
+// of a keypad, the M1 replies with a KC message whose key is NoKey.
+type KeypadStatusRequest struct {
+	Keypad int
+}
+
+func (m *KeypadStatusRequest) Type() string   { return "kc" }
+func (m *KeypadStatusRequest) Encode() []byte { return appendDecInt(nil, m.Keypad, 2) }
+
+func (m *KeypadStatusRequest) Decode(data []byte) error {
+	if err := checkLen("kc", data, 2); err != nil {
+		return err
+	}
+	var err error
+	m.Keypad, _, err = readDecIntN(data, 2)
+	return err
+}
+
+func validateKeypad(keypad int) error {
+	if keypad < 1 || keypad > NumKeypads {
+		return fmt.Errorf("invalid keypad number: %v", keypad)
+	}
+	return nil
+}
+
+// GetKeypadStatus returns the function key illumination, bypass code and
+// beep and chime status of the specified keypad.
+func GetKeypadStatus(ctx context.Context, sess *streamconn.Session, keypad int) (KeypadChange, error) {
+	if err := validateKeypad(keypad); err != nil {
+		return KeypadChange{}, err
+	}
+	var reply KeypadChange
+	err := call(ctx, sess, &KeypadStatusRequest{Keypad: keypad}, &reply)
+	return reply, err
+}
+
+// FunctionKey represents the keys that can be pressed using a kf request,
+// its value is the character used to represent the key in kf and KF
+// messages.
+type FunctionKey byte
+
+const (
+	// SilenceKey is the * key, it is used to silence trouble beeps.
+	SilenceKey       FunctionKey = '0'
+	F1FunctionKey    FunctionKey = '1'
+	F2FunctionKey    FunctionKey = '2'
+	F3FunctionKey    FunctionKey = '3'
+	F4FunctionKey    FunctionKey = '4'
+	F5FunctionKey    FunctionKey = '5'
+	F6FunctionKey    FunctionKey = '6'
+	ChimeFunctionKey FunctionKey = 'C' // M1 Ver. 4.3.2 and later.
+)
+
+var chimeKeyVersion = Version{4, 3, 2}
+
+var (
+	functionKeys     = []FunctionKey{SilenceKey, F1FunctionKey, F2FunctionKey, F3FunctionKey, F4FunctionKey, F5FunctionKey, F6FunctionKey, ChimeFunctionKey}
+	functionKeyNames = []string{"silence", "f1", "f2", "f3", "f4", "f5", "f6", "chime"}
+)
+
+func (k FunctionKey) String() string {
+	for i, fk := range functionKeys {
+		if fk == k {
+			return functionKeyNames[i]
+		}
+	}
+	return fmt.Sprintf("UnknownFunctionKey(%q)", byte(k))
+}
+
+// FunctionKeyNames returns the names of all of the function keys.
+func FunctionKeyNames() []string {
+	return slices.Clone(functionKeyNames)
+}
+
+// ParseFunctionKey parses the name of a function key, * is accepted as
+// an alias for silence.
+func ParseFunctionKey(name string) (FunctionKey, error) {
+	if name == "*" {
+		return SilenceKey, nil
+	}
+	if i := slices.Index(functionKeyNames, strings.ToLower(name)); i >= 0 {
+		return functionKeys[i], nil
+	}
+	return 0, fmt.Errorf("unknown function key: %q", name)
+}
+
+// FunctionKeyRequest (kf) simulates a function key being pressed on a
+// keypad. This is always a single key press, even if the M1 is
+// programmed to require a double press.
+type FunctionKeyRequest struct {
+	Keypad int
+	Key    FunctionKey
+}
+
+func (m *FunctionKeyRequest) Type() string { return "kf" }
+
+func (m *FunctionKeyRequest) Encode() []byte {
+	return append(appendDecInt(nil, m.Keypad, 2), byte(m.Key))
+}
+
+func (m *FunctionKeyRequest) Decode(data []byte) error {
+	if err := checkLen("kf", data, 3); err != nil {
+		return err
+	}
+	keypad, rest, err := readDecIntN(data, 2)
+	if err != nil {
+		return err
+	}
+	m.Keypad, m.Key = keypad, FunctionKey(rest[0])
+	return nil
+}
+
+// ChimeMode represents the chime mode of an area as reported in KF
+// messages.
+type ChimeMode byte
+
+const (
+	ChimeOff ChimeMode = iota
+	ChimeOnly
+	VoiceOnly
+	ChimeAndVoice
+)
+
+var (
+	chimeModeNames = []string{
+		"off",
+		"chime",
+		"voice",
+		"chime+voice",
+	}
+)
+
+func (c ChimeMode) String() string {
+	if int(c) >= len(chimeModeNames) {
+		return fmt.Sprintf("UnknownChimeMode(%v)", int(c))
+	}
+	return chimeModeNames[c]
+}
+
+// FunctionKeyReply (KF) is the reply to a FunctionKeyRequest.
+type FunctionKeyReply struct {
+	Keypad int
+	Key    FunctionKey
+	// ChimeModes is the chime mode of each area.
+	ChimeModes [NumAreas]ChimeMode
+}
+
+func (m *FunctionKeyReply) Type() string { return "KF" }
+
+func (m *FunctionKeyReply) Encode() []byte {
+	buf := append(appendDecInt(nil, m.Keypad, 2), byte(m.Key))
+	for _, c := range m.ChimeModes {
+		buf = append(buf, '0'+byte(c))
+	}
+	return buf
+}
+
+func (m *FunctionKeyReply) Decode(data []byte) error {
+	if err := checkLen("function key", data, 3+NumAreas); err != nil {
+		return err
+	}
+	keypad, rest, err := readDecIntN(data, 2)
+	if err != nil {
+		return err
+	}
+	m.Keypad, m.Key = keypad, FunctionKey(rest[0])
+	for i := range m.ChimeModes {
+		m.ChimeModes[i] = ChimeMode(rest[1+i] - '0')
+	}
+	return nil
+}
+
+// PressFunctionKey simulates pressing the specified key on a keypad.
+func PressFunctionKey(ctx context.Context, sess *streamconn.Session, keypad int, key FunctionKey) (FunctionKeyReply, error) {
+	if err := validateKeypad(keypad); err != nil {
+		return FunctionKeyReply{}, err
+	}
+	if !slices.Contains(functionKeys, key) {
+		return FunctionKeyReply{}, fmt.Errorf("invalid function key: %v", key)
+	}
+	if v := VersionFromContext(ctx); key == ChimeFunctionKey && !v.IsZero() && v.Compare(chimeKeyVersion) < 0 {
+		return FunctionKeyReply{}, fmt.Errorf("the chime key requires M1 version %v or later, this M1 is version %v: %w", chimeKeyVersion, v, ErrUnsupported)
+	}
+	var reply FunctionKeyReply
+	err := call(ctx, sess, &FunctionKeyRequest{Keypad: keypad, Key: key}, &reply)
+	return reply, err
+}
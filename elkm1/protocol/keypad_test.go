@@ -0,0 +1,165 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestDisplayMessage(t *testing.T) {
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"2Edm31000300Garage open^    ^               009C\r\n", &protocol.DisplayMessageRequest{
+			Area: 3, Clear: protocol.DisplayUntilAcknowledge, Seconds: 300, Line1: "Garage open"}},
+		{"2Edm10000000^               ^               005B\r\n", &protocol.DisplayMessageRequest{
+			Area: 1, Clear: protocol.DisplayClearNow}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	// The example from the spec, characters after the ^ are ignored.
+	decodes(t, "2Edm11100020abc^efghijklmnopABCDEF^HIJKLMNOP00B2\r\n", &protocol.DisplayMessageRequest{
+		Area: 1, Clear: protocol.DisplayUntilAcknowledge, Beep: true, Seconds: 20,
+		Line1: "abc", Line2: "ABCDEF"})
+
+	ctx := context.Background()
+	ft, sess := newSession()
+	if err := protocol.DisplayMessage(ctx, sess, protocol.DisplayMessageRequest{
+		Area: 3, Clear: protocol.DisplayUntilAcknowledge, Seconds: 300, Line1: "Garage open"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := protocol.ClearDisplayMessage(ctx, sess, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, req := range []protocol.DisplayMessageRequest{
+		{Area: 9, Line1: "x"},
+		{Area: 1, Clear: protocol.DisplayClear(3)},
+		{Area: 1, Seconds: protocol.MaxDisplayTime + 1},
+		{Area: 1, Line1: "0123456789abcdefg"},
+		{Area: 1, Line2: "a^b"},
+	} {
+		if err := protocol.DisplayMessage(ctx, sess, req); err == nil {
+			t.Errorf("%#v: expected an error", req)
+		}
+	}
+	if got, want := strings.Join(ft.sent, ""), "2Edm31000300Garage open^    ^               009C\r\n"+
+		"2Edm10000000^               ^               005B\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestKeypads(t *testing.T) {
+	areas := protocol.KeypadAreasReply{Areas: [protocol.NumKeypads]int{1, 2, 3, 4, 5, 6, 7, 8, 1, 1, 1, 1, 1, 1, 1, 1}}
+	status := protocol.KeypadChange{
+		Keypad:       1,
+		Key:          protocol.NoKey,
+		Illumination: [protocol.NumFunctionKeys]protocol.Illumination{protocol.IlluminationOn},
+	}
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"06ka006E\r\n", &protocol.KeypadAreasRequest{}},
+		{"16KA12345678111111110081\r\n", &areas},
+		{"19KC01112010000200000000010\r\n", &protocol.KeypadChange{
+			Keypad:       1,
+			Key:          protocol.StarKey,
+			Illumination: [protocol.NumFunctionKeys]protocol.Illumination{protocol.IlluminationBlinking, 0, protocol.IlluminationOn},
+			BeepChime:    [protocol.NumAreas]protocol.BeepChime{protocol.ConstantBeep},
+		}},
+		{"08kc010009\r\n", &protocol.KeypadStatusRequest{Keypad: 1}},
+		{"09kf01100D4\r\n", &protocol.FunctionKeyRequest{Keypad: 1, Key: protocol.F1FunctionKey}},
+		{"11KF01C200000000087\r\n", &protocol.FunctionKeyReply{
+			Keypad: 1, Key: protocol.ChimeFunctionKey, ChimeModes: [protocol.NumAreas]protocol.ChimeMode{protocol.VoiceOnly}}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	// KC messages from older firmware are shorter.
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"0AKC011100DE\r\n", &protocol.KeypadChange{Keypad: 1, Key: protocol.StarKey}},
+		{"11KC01001000000009E\r\n", &status},
+	} {
+		decodes(t, tc.msg, tc.decoded)
+	}
+
+	for _, tc := range []struct {
+		bc   protocol.BeepChime
+		name string
+	}{
+		{0, "off"},
+		{protocol.ConstantBeep, "constant-beep"},
+		{protocol.Chime | protocol.Voice, "chime+voice"},
+	} {
+		if got, want := tc.bc.String(), tc.name; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	for _, name := range protocol.FunctionKeyNames() {
+		k, err := protocol.ParseFunctionKey(name)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", name, err)
+		}
+		if got, want := k.String(), name; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if _, err := protocol.ParseFunctionKey("f7"); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	ctx := context.Background()
+	ft, sess := newSession(
+		"16KA12345678111111110081\r\n",
+		"11KC01001000000009E\r\n",
+		"11KF01C200000000087\r\n",
+	)
+	ka, err := protocol.GetKeypadAreas(ctx, sess)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := ka, areas; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	kc, err := protocol.GetKeypadStatus(ctx, sess, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := kc, status; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	kf, err := protocol.PressFunctionKey(ctx, sess, 1, protocol.ChimeFunctionKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := kf.ChimeModes[0], protocol.VoiceOnly; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := protocol.PressFunctionKey(ctx, sess, 17, protocol.F1FunctionKey); err == nil {
+		t.Errorf("expected an error for an invalid keypad")
+	}
+	if _, err := protocol.PressFunctionKey(ctx, sess, 1, protocol.FunctionKey('7')); err == nil {
+		t.Errorf("expected an error for an invalid key")
+	}
+	old := protocol.ContextWithVersion(ctx, protocol.Version{Major: 4, Minor: 3, Patch: 0})
+	if _, err := protocol.PressFunctionKey(old, sess, 1, protocol.ChimeFunctionKey); !errors.Is(err, protocol.ErrUnsupported) {
+		t.Errorf("unexpected or missing error: %v", err)
+	}
+	if got, want := strings.Join(ft.sent, ""), "06ka006E\r\n08kc010009\r\n09kf01C00C2\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
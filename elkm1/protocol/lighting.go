@@ -0,0 +1,370 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+const (
+	// NumLightingDevices is the number of lighting devices, A1 to P16.
+	NumLightingDevices = 256
+	// NumLightingBanks is the number of banks of lighting devices reported
+	// on by a LightingStatusRequest.
+	NumLightingBanks = 4
+	// LightingDevicesPerBank is the number of lighting devices in a bank,
+	// ie. four house codes.
+	LightingDevicesPerBank = NumLightingDevices / NumLightingBanks
+	// MaxLightingTime is the maximum time, in seconds, that may be
+	// specified for a LightingControlRequest.
+	MaxLightingTime = 9999
+)
+
+// LightingFunction is the function performed by a LightingControlRequest,
+// these were originally defined for X10 but are also interpreted by other
+// lighting systems integrated with the M1.
+type LightingFunction int
+
+const (
+	X10AllUnitsOff  LightingFunction = 1
+	X10AllLightsOn  LightingFunction = 2
+	X10UnitOn       LightingFunction = 3
+	X10UnitOff      LightingFunction = 4
+	X10Dim          LightingFunction = 5 // Extended is the number of dims.
+	X10Bright       LightingFunction = 6 // Extended is the number of brights.
+	X10AllLightsOff LightingFunction = 7
+	X10ExtendedCode LightingFunction = 8
+	X10PresetDim    LightingFunction = 9 // Extended is the level, 0 to 99%.
+	X10ExtendedData LightingFunction = 10
+	X10StatusReq    LightingFunction = 11
+	X10HailRequest  LightingFunction = 12
+)
+
+// The lighting levels reported by the M1, levels 2 to 99 are dim levels.
+const (
+	LightOff = 0
+	LightOn  = 1
+)
+
+// LightingDeviceName returns the house code and unit name, eg. A1, for
+// the lighting device numbered 1 to 256.
+func LightingDeviceName(device int) string {
+	return fmt.Sprintf("%c%d", 'A'+byte((device-1)/16), (device-1)%16+1)
+}
+
+// ParseLightingDevice parses a lighting device specified either as a
+// number from 1 to 256, or as a house code and unit, eg. A1 or P16.
+func ParseLightingDevice(device string) (int, error) {
+	if n, err := strconv.Atoi(device); err == nil {
+		if err := validateLightingDevice(n); err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+	if len(device) < 2 {
+		return 0, fmt.Errorf("invalid lighting device: %q", device)
+	}
+	house := strings.ToUpper(device[:1])[0]
+	unit, err := strconv.Atoi(device[1:])
+	if house < 'A' || house > 'P' || err != nil || unit < 1 || unit > 16 {
+		return 0, fmt.Errorf("invalid lighting device: %q", device)
+	}
+	return int(house-'A')*16 + unit, nil
+}
+
+func validateLightingDevice(device int) error {
+	if device < 1 || device > NumLightingDevices {
+		return fmt.Errorf("invalid lighting device number: %v", device)
+	}
+	return nil
+}
+
+func appendLightingDevice(buf []byte, device int) []byte {
+	return appendDecInt(append(buf, 'A'+byte((device-1)/16)), (device-1)%16+1, 2)
+}
+
+func readLightingDevice(data []byte) (int, []byte, error) {
+	if len(data) < 3 || data[0] < 'A' || data[0] > 'P' {
+		return 0, data, fmt.Errorf("invalid lighting device: %q", data)
+	}
+	house := int(data[0] - 'A')
+	unit, data, err := readDecIntN(data[1:], 2)
+	if err != nil {
+		return 0, data, err
+	}
+	if unit < 1 || unit > 16 {
+		return 0, data, fmt.Errorf("invalid lighting unit: %v", unit)
+	}
+	return house*16 + unit, data, nil
+}
+
+// LightingControlRequest (pc) requests that the M1 perform the specified
+// function on a lighting device. The interpretation of Seconds depends on
+// the function and lighting system, eg. the on time for X10 or the
+// transition time for a preset dim on some integrated systems.
+type LightingControlRequest struct {
+	Device   int
+	Function LightingFunction
+	Extended int
+	Seconds  int
+}
+
+func (m *LightingControlRequest) Type() string { return "pc" }
+
+func (m *LightingControlRequest) Encode() []byte {
+	buf := appendLightingDevice(nil, m.Device)
+	buf = appendDecInt(buf, int(m.Function), 2)
+	buf = appendDecInt(buf, m.Extended, 2)
+	return appendDecInt(buf, m.Seconds, 4)
+}
+
+func (m *LightingControlRequest) Decode(data []byte) error {
+	if err := checkLen("pc", data, 3+2+2+4); err != nil {
+		return err
+	}
+	var err error
+	if m.Device, data, err = readLightingDevice(data); err != nil {
+		return err
+	}
+	var fn int
+	if fn, data, err = readDecIntN(data, 2); err != nil {
+		return err
+	}
+	m.Function = LightingFunction(fn)
+	if m.Extended, data, err = readDecIntN(data, 2); err != nil {
+		return err
+	}
+	m.Seconds, _, err = readDecIntN(data, 4)
+	return err
+}
+
+// LightingChange (PC) is sent when a lighting device changes state. For
+// the all units off, all lights on and all lights off functions Unit is
+// zero and Level is the function.
+type LightingChange struct {
+	House byte
+	Unit  int
+	Level int
+}
+
+func (m *LightingChange) Type() string { return "PC" }
+
+// Device returns the lighting device number, or zero if the change
+// applies to all of the devices with the same house code.
+func (m *LightingChange) Device() int {
+	if m.Unit == 0 {
+		return 0
+	}
+	return int(m.House-'A')*16 + m.Unit
+}
+
+func (m *LightingChange) Encode() []byte {
+	return appendDecInt(appendDecInt([]byte{m.House}, m.Unit, 2), m.Level, 2)
+}
+
+func (m *LightingChange) Decode(data []byte) error {
+	if err := checkLen("lighting change", data, 1+2+2); err != nil {
+		return err
+	}
+	if data[0] < 'A' || data[0] > 'P' {
+		return fmt.Errorf("invalid house code: %q", data[0])
+	}
+	m.House = data[0]
+	var err error
+	if m.Unit, data, err = readDecIntN(data[1:], 2); err != nil {
+		return err
+	}
+	m.Level, _, err = readDecIntN(data, 2)
+	return err
+}
+
+func decodeLightingDeviceRequest(what string, data []byte) (int, error) {
+	if err := checkLen(what, data, 3); err != nil {
+		return 0, err
+	}
+	device, _, err := readLightingDevice(data)
+	return device, err
+}
+
+// LightingOffRequest (pf) turns a lighting device off.
+type LightingOffRequest struct {
+	Device int
+}
+
+func (m *LightingOffRequest) Type() string   { return "pf" }
+func (m *LightingOffRequest) Encode() []byte { return appendLightingDevice(nil, m.Device) }
+
+func (m *LightingOffRequest) Decode(data []byte) error {
+	var err error
+	m.Device, err = decodeLightingDeviceRequest("pf", data)
+	return err
+}
+
+// LightingOnRequest (pn) turns a lighting device on.
+type LightingOnRequest struct {
+	Device int
+}
+
+func (m *LightingOnRequest) Type() string   { return "pn" }
+func (m *LightingOnRequest) Encode() []byte { return appendLightingDevice(nil, m.Device) }
+
+func (m *LightingOnRequest) Decode(data []byte) error {
+	var err error
+	m.Device, err = decodeLightingDeviceRequest("pn", data)
+	return err
+}
+
+// LightingToggleRequest (pt) toggles a lighting device.
+type LightingToggleRequest struct {
+	Device int
+}
+
+func (m *LightingToggleRequest) Type() string   { return "pt" }
+func (m *LightingToggleRequest) Encode() []byte { return appendLightingDevice(nil, m.Device) }
+
+func (m *LightingToggleRequest) Decode(data []byte) error {
+	var err error
+	m.Device, err = decodeLightingDeviceRequest("pt", data)
+	return err
+}
+
+// LightingStatusRequest (ps) requests the status of a bank of 64 lighting
+// devices, bank 0 is A1 to D16, bank 1 E1 to H16, bank 2 I1 to L16 and
+// bank 3 M1 to P16.
+type LightingStatusRequest struct {
+	Bank int
+}
+
+func (m *LightingStatusRequest) Type() string   { return "ps" }
+func (m *LightingStatusRequest) Encode() []byte { return appendDecInt(nil, m.Bank, 1) }
+
+func (m *LightingStatusRequest) Decode(data []byte) error {
+	if err := checkLen("ps", data, 1); err != nil {
+		return err
+	}
+	var err error
+	m.Bank, _, err = readDecIntN(data, 1)
+	return err
+}
+
+// LightingStatusReply (PS) is the reply to a LightingStatusRequest, the
+// level of each device is LightOff, LightOn or a dim level of 2 to 99.
+type LightingStatusReply struct {
+	Bank   int
+	Levels [LightingDevicesPerBank]int
+}
+
+func (m *LightingStatusReply) Type() string { return "PS" }
+
+func (m *LightingStatusReply) Encode() []byte {
+	buf := appendDecInt(nil, m.Bank, 1)
+	for _, l := range m.Levels {
+		buf = append(buf, '0'+byte(l))
+	}
+	return buf
+}
+
+func (m *LightingStatusReply) Decode(data []byte) error {
+	if err := checkLen("lighting status", data, 1+LightingDevicesPerBank); err != nil {
+		return err
+	}
+	var err error
+	if m.Bank, data, err = readDecIntN(data, 1); err != nil {
+		return err
+	}
+	// Levels are encoded as a single character offset from '0'.
+	for i, c := range data {
+		if c < '0' || c > '0'+99 {
+			return fmt.Errorf("invalid lighting level: %q", c)
+		}
+		m.Levels[i] = int(c - '0')
+	}
+	return nil
+}
+
+// LightingStatusAll contains the level of all lighting devices, indexed
+// by device number - 1.
+type LightingStatusAll [NumLightingDevices]int
+
+// LightingControl performs the specified function on a lighting device.
+func LightingControl(ctx context.Context, sess *streamconn.Session, device int, fn LightingFunction, extended, seconds int) error {
+	if err := validateLightingDevice(device); err != nil {
+		return err
+	}
+	if extended < 0 || extended > 99 {
+		return fmt.Errorf("invalid extended value: %v, must be between 0 and 99", extended)
+	}
+	if seconds < 0 || seconds > MaxLightingTime {
+		return fmt.Errorf("invalid time: %v, must be between 0 and %v seconds", seconds, MaxLightingTime)
+	}
+	return send(ctx, sess, &LightingControlRequest{Device: device, Function: fn, Extended: extended, Seconds: seconds})
+}
+
+// LightingLevel sets a lighting device to the specified level, 0 to
+// 99%, using a preset dim with the specified time.
+func LightingLevel(ctx context.Context, sess *streamconn.Session, device, level, seconds int) error {
+	if level < 0 || level > 99 {
+		return fmt.Errorf("invalid lighting level: %v, must be between 0 and 99", level)
+	}
+	return LightingControl(ctx, sess, device, X10PresetDim, level, seconds)
+}
+
+// LightingOn turns the specified lighting device on.
+func LightingOn(ctx context.Context, sess *streamconn.Session, device int) error {
+	if err := validateLightingDevice(device); err != nil {
+		return err
+	}
+	return send(ctx, sess, &LightingOnRequest{Device: device})
+}
+
+// LightingOff turns the specified lighting device off.
+func LightingOff(ctx context.Context, sess *streamconn.Session, device int) error {
+	if err := validateLightingDevice(device); err != nil {
+		return err
+	}
+	return send(ctx, sess, &LightingOffRequest{Device: device})
+}
+
+// LightingToggle toggles the specified lighting device.
+func LightingToggle(ctx context.Context, sess *streamconn.Session, device int) error {
+	if err := validateLightingDevice(device); err != nil {
+		return err
+	}
+	return send(ctx, sess, &LightingToggleRequest{Device: device})
+}
+
+// GetLightingBankStatus returns the levels of the devices in the
+// specified bank.
+func GetLightingBankStatus(ctx context.Context, sess *streamconn.Session, bank int) ([LightingDevicesPerBank]int, error) {
+	if bank < 0 || bank >= NumLightingBanks {
+		return [LightingDevicesPerBank]int{}, fmt.Errorf("invalid lighting bank: %v", bank)
+	}
+	var reply LightingStatusReply
+	if err := call(ctx, sess, &LightingStatusRequest{Bank: bank}, &reply); err != nil {
+		return [LightingDevicesPerBank]int{}, err
+	}
+	if reply.Bank != bank {
+		return [LightingDevicesPerBank]int{}, fmt.Errorf("unexpected lighting bank: got %v, expected %v", reply.Bank, bank)
+	}
+	return reply.Levels, nil
+}
+
+// GetLightingStatusAll returns the levels of all lighting devices.
+func GetLightingStatusAll(ctx context.Context, sess *streamconn.Session) (LightingStatusAll, error) {
+	var all LightingStatusAll
+	for bank := 0; bank < NumLightingBanks; bank++ {
+		levels, err := GetLightingBankStatus(ctx, sess, bank)
+		if err != nil {
+			return LightingStatusAll{}, err
+		}
+		copy(all[bank*LightingDevicesPerBank:], levels[:])
+	}
+	return all, nil
+}
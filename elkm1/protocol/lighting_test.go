@@ -0,0 +1,102 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestLighting(t *testing.T) {
+	bank0 := protocol.LightingStatusReply{}
+	for i := 1; i < 16; i++ {
+		bank0.Levels[i] = protocol.LightOn
+	}
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"11pcA01010000050043\r\n", &protocol.LightingControlRequest{Device: 1, Function: protocol.X10AllUnitsOff, Seconds: 5}},
+		{"0BPCA01000099\r\n", &protocol.LightingChange{House: 'A', Unit: 1, Level: protocol.LightOff}},
+		{"09pfA0100BF\r\n", &protocol.LightingOffRequest{Device: 1}},
+		{"09pnA0100B7\r\n", &protocol.LightingOnRequest{Device: 1}},
+		{"09ptA0100B1\r\n", &protocol.LightingToggleRequest{Device: 1}},
+		{"07ps00026\r\n", &protocol.LightingStatusRequest{Bank: 0}},
+		{"47PS001111111111111110000000000000000000000000000000000000000000000000053\r\n", &bank0},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	for _, tc := range []struct {
+		spec   string
+		device int
+		name   string
+	}{
+		{"A1", 1, "A1"},
+		{"a16", 16, "A16"},
+		{"B2", 18, "B2"},
+		{"P16", 256, "P16"},
+		{"18", 18, "B2"},
+	} {
+		device, err := protocol.ParseLightingDevice(tc.spec)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.spec, err)
+			continue
+		}
+		if got, want := device, tc.device; got != want {
+			t.Errorf("%v: got %v, want %v", tc.spec, got, want)
+		}
+		if got, want := protocol.LightingDeviceName(device), tc.name; got != want {
+			t.Errorf("%v: got %v, want %v", tc.spec, got, want)
+		}
+	}
+	for _, spec := range []string{"", "Q1", "A0", "A17", "257", "0"} {
+		if _, err := protocol.ParseLightingDevice(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+
+	ctx := context.Background()
+	ft, sess := newSession(
+		"47PS001111111111111110000000000000000000000000000000000000000000000000053\r\n",
+		"47PS1000000000000000000000000000000000000000000000000000000000000000b002F\r\n",
+		"47PS200000000000000000000000000000000000000000000000000000000000000000060\r\n",
+		"47PS30000000000000000000000000000000000000000000000000000000000000000005F\r\n",
+	)
+	defer sess.Release()
+	all, err := protocol.GetLightingStatusAll(ctx, sess)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := all[0], protocol.LightOff; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := all[1], protocol.LightOn; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := all[127], 50; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := strings.Join(ft.sent, ""), "07ps00026\r\n07ps10025\r\n07ps20024\r\n07ps30023\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	ft.sent = nil
+	if err := protocol.LightingLevel(ctx, sess, 18, 50, 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := strings.Join(ft.sent, ""), "11pcB02095000030036\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := protocol.LightingLevel(ctx, sess, 18, 100, 0); err == nil {
+		t.Errorf("expected an error for an invalid level")
+	}
+	if err := protocol.LightingOn(ctx, sess, 257); err == nil {
+		t.Errorf("expected an error for an invalid device")
+	}
+}
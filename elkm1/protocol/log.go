@@ -0,0 +1,208 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+// NumLogEntries is the number of entries in the M1's event log, entry 1
+// is the most recent.
+const NumLogEntries = 511
+
+// LogRequest (ld) requests a single entry from the event log, M1 Ver.
+// 4.3.2 and later.
+type LogRequest struct {
+	Index int
+}
+
+func (m *LogRequest) Type() string   { return "ld" }
+func (m *LogRequest) Encode() []byte { return appendDecInt(nil, m.Index, 3) }
+
+func (m *LogRequest) Decode(data []byte) error {
+	if err := checkLen("ld", data, 3); err != nil {
+		return err
+	}
+	var err error
+	m.Index, _, err = readDecIntN(data, 3)
+	return err
+}
+
+// LogEntry (LD) is the reply to a LogRequest and is also sent as entries
+// are written to the log if enabled in the M1's global programming.
+// Number is the zone, user etc. that the event refers to. Time is in the
+// local time zone, has a resolution of one minute and is zero for
+// empty entries. DayOfWeek is as reported by the M1, 1 for Sunday.
+type LogEntry struct {
+	Event     int
+	Number    int
+	Area      int
+	Time      time.Time
+	Index     int
+	DayOfWeek int
+}
+
+func (m *LogEntry) Type() string { return "LD" }
+
+func (m *LogEntry) Encode() []byte {
+	buf := appendDecInt(nil, m.Event, 4)
+	buf = appendDecInt(buf, m.Number, 3)
+	buf = appendDecInt(buf, m.Area, 1)
+	var hour, minute, month, day, year int
+	if !m.Time.IsZero() {
+		hour, minute = m.Time.Hour(), m.Time.Minute()
+		month, day, year = int(m.Time.Month()), m.Time.Day(), m.Time.Year()-2000
+	}
+	for _, v := range []int{hour, minute, month, day} {
+		buf = appendDecInt(buf, v, 2)
+	}
+	buf = appendDecInt(buf, m.Index, 3)
+	buf = appendDecInt(buf, m.DayOfWeek, 1)
+	return appendDecInt(buf, year, 2)
+}
+
+func (m *LogEntry) Decode(data []byte) error {
+	if err := checkLen("log entry", data, 4+3+1+2*4+3+1+2); err != nil {
+		return err
+	}
+	var fields [10]int
+	for i, n := range []int{4, 3, 1, 2, 2, 2, 2, 3, 1, 2} {
+		var err error
+		if fields[i], data, err = readDecIntN(data, n); err != nil {
+			return err
+		}
+	}
+	m.Event, m.Number, m.Area = fields[0], fields[1], fields[2]
+	hour, minute, month, day := fields[3], fields[4], fields[5], fields[6]
+	m.Index, m.DayOfWeek = fields[7], fields[8]
+	m.Time = time.Time{}
+	if month != 0 && day != 0 {
+		m.Time = time.Date(2000+fields[9], time.Month(month), day, hour, minute, 0, 0, time.Local)
+	}
+	return nil
+}
+
+// Empty returns true if the log entry has never been written.
+func (m *LogEntry) Empty() bool {
+	return m.Event == 0 && m.Time.IsZero()
+}
+
+// LogType is the type of an event written to the log by a LogWriteRequest.
+type LogType int
+
+const (
+	LogAlarmRestore LogType = 64
+	LogAlarm        LogType = 128
+)
+
+// MaxLogWriteEvent is the largest event type that can be written to the log.
+const MaxLogWriteEvent = 386
+
+// LogWriteRequest (le) writes an event to the log and reports it to the
+// central station if the zone is programmed for communicator reporting.
+// Event is the event type with the leading thousands digit removed, eg.
+// 1001 becomes 1.
+type LogWriteRequest struct {
+	LogType LogType
+	Event   int
+	Zone    int
+	Area    int
+}
+
+func (m *LogWriteRequest) Type() string { return "le" }
+
+func (m *LogWriteRequest) Encode() []byte {
+	buf := appendDecInt(nil, int(m.LogType), 3)
+	buf = appendDecInt(buf, m.Event, 3)
+	buf = appendDecInt(buf, m.Zone, 3)
+	return appendDecInt(buf, m.Area, 1)
+}
+
+func (m *LogWriteRequest) Decode(data []byte) error {
+	if err := checkLen("le", data, 3+3+3+1); err != nil {
+		return err
+	}
+	var typ int
+	var err error
+	if typ, data, err = readDecIntN(data, 3); err != nil {
+		return err
+	}
+	m.LogType = LogType(typ)
+	if m.Event, data, err = readDecIntN(data, 3); err != nil {
+		return err
+	}
+	if m.Zone, data, err = readDecIntN(data, 3); err != nil {
+		return err
+	}
+	m.Area, _, err = readDecIntN(data, 1)
+	return err
+}
+
+// GetLogEntry returns the specified log entry, 1 is the most recent.
+func GetLogEntry(ctx context.Context, sess *streamconn.Session, index int) (LogEntry, error) {
+	if index < 1 || index > NumLogEntries {
+		return LogEntry{}, fmt.Errorf("invalid log index: %v", index)
+	}
+	req := &LogRequest{Index: index}
+	if err := checkSupported(ctx, req); err != nil {
+		return LogEntry{}, err
+	}
+	d := DispatcherFromContext(ctx)
+	resp := responseFor(&LogEntry{})
+	data, err := d.RPC(ctx, sess, Encode(req), resp)
+	for {
+		if err != nil {
+			return LogEntry{}, err
+		}
+		var reply LogEntry
+		if err := reply.Decode(data); err != nil {
+			return LogEntry{}, err
+		}
+		if reply.Index == index {
+			return reply, nil
+		}
+		// The M1 may be configured to send LD as its log is written and
+		// such an update may arrive before the reply, eg. whilst walking
+		// the log, so deliver it as an unsolicited message and keep waiting.
+		d.Deliver(ctx, Frame{Type: 'L', SubType: 'D', Data: data})
+		data, err = d.WaitFor(ctx, sess, resp)
+	}
+}
+
+// WalkLog calls fn for each log entry, starting with the most recent,
+// until fn returns false or an empty entry is reached.
+func WalkLog(ctx context.Context, sess *streamconn.Session, fn func(LogEntry) bool) error {
+	for index := 1; index <= NumLogEntries; index++ {
+		entry, err := GetLogEntry(ctx, sess, index)
+		if err != nil {
+			return err
+		}
+		if entry.Empty() || !fn(entry) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// WriteLog writes an event to the log, see LogWriteRequest.
+func WriteLog(ctx context.Context, sess *streamconn.Session, typ LogType, event, zone, area int) error {
+	if typ != LogAlarm && typ != LogAlarmRestore {
+		return fmt.Errorf("invalid log type: %v", typ)
+	}
+	if event < 1 || event > MaxLogWriteEvent {
+		return fmt.Errorf("invalid log event: %v, must be between 1 and %v", event, MaxLogWriteEvent)
+	}
+	if zone < 1 || zone > 999 {
+		return fmt.Errorf("invalid log zone: %v", zone)
+	}
+	if err := validateArea(area); err != nil {
+		return err
+	}
+	return send(ctx, sess, &LogWriteRequest{LogType: typ, Event: event, Zone: zone, Area: area})
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestLog(t *testing.T) {
+	armed := protocol.LogEntry{
+		Event:     1193,
+		Number:    102,
+		Area:      1,
+		Time:      time.Date(2005, 6, 7, 19, 45, 0, 0, time.Local),
+		Index:     1,
+		DayOfWeek: 5,
+	}
+	disarmed := protocol.LogEntry{
+		Event:     1174,
+		Number:    1,
+		Area:      1,
+		Time:      time.Date(2024, 6, 10, 7, 30, 0, 0, time.Local),
+		Index:     2,
+		DayOfWeek: 2,
+	}
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"09ld00100D6\r\n", &protocol.LogRequest{Index: 1}},
+		{"1CLD1193102119450607001505003F\r\n", &armed},
+		{"1CLD11740011073006100022240052\r\n", &disarmed},
+		{"1CLD0000000000000000000000007C\r\n", &protocol.LogEntry{}},
+		{"10le1281020201007D\r\n", &protocol.LogWriteRequest{LogType: protocol.LogAlarm, Event: 102, Zone: 20, Area: 1}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	ctx := context.Background()
+	ft, sess := newSession(
+		"1CLD1193102119450607001505003F\r\n",
+		"1CLD11740011073006100022240052\r\n",
+		"1CLD00000000000000000030000079\r\n",
+	)
+	defer sess.Release()
+	var entries []protocol.LogEntry
+	err := protocol.WalkLog(ctx, sess, func(e protocol.LogEntry) bool {
+		entries = append(entries, e)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := entries, []protocol.LogEntry{armed, disarmed}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := strings.Join(ft.sent, ""), "09ld00100D6\r\n09ld00200D5\r\n09ld00300D4\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// An LD sent as the log is written, with a different index, is
+	// delivered to the handlers rather than being mistaken for the reply.
+	d := protocol.NewDispatcher()
+	var updates []int
+	d.Handle('L', 'D', func(_ context.Context, f protocol.Frame) {
+		var e protocol.LogEntry
+		if err := e.Decode(f.Data); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		updates = append(updates, e.Index)
+	})
+	ft, sess = newSession(
+		"1CLD11931021194506070005050040\r\n",
+		"1CLD1193102119450607001505003F\r\n",
+	)
+	defer sess.Release()
+	entry, err := protocol.GetLogEntry(protocol.ContextWithDispatcher(ctx, d), sess, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := entry, armed; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := updates, []int{0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := strings.Join(ft.sent, ""), "09ld00100D6\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	ft.sent = nil
+	if err := protocol.WriteLog(ctx, sess, protocol.LogAlarm, 102, 20, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := strings.Join(ft.sent, ""), "10le1281020201007D\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := protocol.WriteLog(ctx, sess, protocol.LogType(1), 102, 20, 1); err == nil {
+		t.Errorf("expected an error for an invalid log type")
+	}
+	if err := protocol.WriteLog(ctx, sess, protocol.LogAlarm, 387, 20, 1); err == nil {
+		t.Errorf("expected an error for an invalid event")
+	}
+}
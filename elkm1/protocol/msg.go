@@ -17,27 +17,29 @@ var (
 	hexLookup = []byte("0123456789ABCDEF")
 )
 
+// Request provides the encoded form of commonly used requests and the
+// Response used to recognise their replies.
 type Request struct{}
 
 func (r Request) RealTime() ([]byte, Response) {
-	return formatMessage('r', 'r', nil), Response{Type: 'R', SubType: 'R'}
+	return Encode(&RealTimeRequest{}), responseFor(&RealTimeReply{})
 }
 
 func (r Request) ZoneDefinitions() ([]byte, Response) {
-	return formatMessage('z', 'd', nil), Response{Type: 'Z', SubType: 'D'}
+	return Encode(&ZoneDefinitionsRequest{}), responseFor(&ZoneDefinitionsReply{})
 }
 
 func (r Request) ZoneName(z int) ([]byte, Response) {
-	data := [5]byte{}
-	data[0], data[1] = '0', '0'
-	data[2] = byte(z/100 + '0')
-	data[3] = byte((z%100)/10 + '0')
-	data[4] = byte(z%10 + '0')
-	return formatMessage('s', 'd', data[:]), Response{Type: 'S', SubType: 'D'}
+	return Encode(&TextDescriptionRequest{Kind: ZoneDescription, Index: z}), responseFor(&TextDescriptionReply{})
 }
 
 func (r Request) ZoneStatus() ([]byte, Response) {
-	return formatMessage('z', 's', nil), Response{Type: 'Z', SubType: 'S'}
+	return Encode(&ZoneStatusRequest{}), responseFor(&ZoneStatusReply{})
+}
+
+func responseFor(m Message) Response {
+	typ := m.Type()
+	return Response{Type: typ[0], SubType: typ[1]}
 }
 
 type Response struct {
@@ -55,6 +57,14 @@ func (r Response) Expected(msg []byte) ([]byte, error) {
 	return data, nil
 }
 
+// reservedAsData contains the messages that use the reserved bytes to
+// carry data, eg. the AS message uses them for the exit/entry timer
+// on M1 Ver. 4.11 and later. Decode returns these bytes as the last two
+// bytes of the data.
+var reservedAsData = map[[2]byte]bool{
+	{'A', 'S'}: true,
+}
+
 const msgOverhead = 2 + // len
 	2 + // type, subtype
 	2 + // reserved
@@ -67,6 +77,10 @@ func formatMessage(typ, subtype byte, data []byte) []byte {
 	// format is:
 	// len[2], type[1], subtype[1], data[:], reserved[2]('0'), crc[2], cr, lf
 
+	reserved := []byte{'0', '0'}
+	if reservedAsData[[2]byte{typ, subtype}] && len(data) >= 2 {
+		data, reserved = data[:len(data)-2], data[len(data)-2:]
+	}
 	tl := len(data) + msgOverhead // total length
 	buf := make([]byte, tl)
 	ml := tl - 4 // (length and crlf) excluded from the in-message length.
@@ -77,8 +91,8 @@ func formatMessage(typ, subtype byte, data []byte) []byte {
 	hd := 4
 	copy(buf[hd:], data)
 	hd += len(data)
-	buf[hd] = '0'
-	buf[hd+1] = '0'
+	buf[hd] = reserved[0]
+	buf[hd+1] = reserved[1]
 	hd += 2
 	crc := byte(0)
 	for i := 0; i < hd; i++ {
@@ -119,6 +133,10 @@ func (r Response) Decode(buf []byte) (typ, subtype byte, data []byte, err error)
 		return
 	}
 	ml, pbuf := readHexInt(buf) // message length excludes the length and crlf
+	if ml < 6 {
+		err = fmt.Errorf("message length %v is too short, no type, reserved or crc bytes", ml)
+		return
+	}
 	typ = pbuf[0]
 	subtype = pbuf[1]
 	pbuf = pbuf[2:]
@@ -129,13 +147,16 @@ func (r Response) Decode(buf []byte) (typ, subtype byte, data []byte, err error)
 	// data excludes the message length, reserved and crc which is included in the message length
 	data = slices.Clone(pbuf[:ml-6])
 	pbuf = pbuf[len(data):]
-	if pbuf[0] != '0' || pbuf[1] != '0' {
+	dl := len(data)
+	if reservedAsData[[2]byte{typ, subtype}] {
+		data = append(data, pbuf[0], pbuf[1])
+	} else if pbuf[0] != '0' || pbuf[1] != '0' {
 		err = fmt.Errorf("invalid reserved bytes: %v", pbuf[:2])
 		return
 	}
 	pbuf = pbuf[2:]                 // skip the reserved bytes
 	crc, pbuf := readHexIntu8(pbuf) // read the crc
-	for i := 0; i < 4+len(data)+2; i++ {
+	for i := 0; i < 4+dl+2; i++ {
 		crc += buf[i]
 	}
 	if crc != 0 {
@@ -151,6 +172,39 @@ func readDecInt(buf []byte) (int, []byte) {
 	return int(buf[0]-'0')*10 + int(buf[1]-'0'), buf[2:]
 }
 
+// readDecIntN reads an n digit, zero padded, decimal number.
+func readDecIntN(buf []byte, n int) (int, []byte, error) {
+	if len(buf) < n {
+		return 0, buf, fmt.Errorf("too short for a %v digit number: %q", n, buf)
+	}
+	v := 0
+	for _, b := range buf[:n] {
+		if b < '0' || b > '9' {
+			return 0, buf, fmt.Errorf("invalid decimal number: %q", buf[:n])
+		}
+		v = v*10 + int(b-'0')
+	}
+	return v, buf[n:], nil
+}
+
+// appendDecInt appends v as an n digit, zero padded, decimal number.
+func appendDecInt(buf []byte, v, n int) []byte {
+	d := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		d[i] = byte(v%10) + '0'
+		v /= 10
+	}
+	return append(buf, d...)
+}
+
+// checkLen returns an error if data is not exactly n bytes long.
+func checkLen(what string, data []byte, n int) error {
+	if got, want := len(data), n; got != want {
+		return fmt.Errorf("unexpected response size for %v: got %v, expected %v", what, got, want)
+	}
+	return nil
+}
+
 // ParseTime parses the time from the data returned by an RR reponses or XK message.
 func ParseTime(data []byte) (time.Time, bool, error) {
 	secs, data := readDecInt(data)
@@ -181,50 +235,26 @@ func (r Response) IsExpected(buf []byte) (bool, error) {
 	return pbuf[0] == r.Type && pbuf[1] == r.SubType, nil
 }
 
+// rpc sends req and waits for the reply described by resp, any other
+// messages received in the meantime are delivered to the dispatcher
+// stored in ctx, or discarded if there is none.
 func rpc(ctx context.Context, sess *streamconn.Session, req []byte, resp Response) ([]byte, error) {
-	sess.Send(ctx, req)
-	var msg []byte
-	for {
-		var err error
-		msg, err = sess.ReadUntil(ctx, "\r\n")
-		if err != nil {
-			return nil, err
-		}
-		ok, err := resp.IsExpected(msg)
-		if err != nil {
-			return nil, err
-		}
-		if ok {
-			break
-		}
-	}
-	data, err := resp.Expected(msg)
-	if err != nil {
-		return nil, err
-	}
-	return data, nil
+	return DispatcherFromContext(ctx).RPC(ctx, sess, req, resp)
 }
 
 // ParseTextDescription parses the text description of a zone or other entity,
 // as in the response to a sd request to obtain the text name of a zone.
 func ParseTextDescription(data []byte) (int, string, error) {
-	if got, want := len(data), 2+3+16; got != want {
-		return 0, "", fmt.Errorf("unexpected response size for text description: got %v, expected %v", got, want)
+	var td TextDescriptionReply
+	if err := td.Decode(data); err != nil {
+		return 0, "", err
 	}
-	data = data[2:]
-	id := int(data[0]-'0')*100 + int(data[1]-'0')*10 + int(data[2]-'0')
-	data = data[3:]
-	return id, string(data), nil
+	return td.Index, td.Name, nil
 }
 
 // ParseZoneStatus parses the status of a zone as returned by a ZS request.
 func ParseZoneStatus(data []byte) (ZoneStatusAll, error) {
-	var status ZoneStatusAll
-	if got, want := len(data), NumZones; got != want {
-		return status, fmt.Errorf("unexpected response size for zone status: got %v, expected %v", got, want)
-	}
-	for i, s := range data {
-		status[i] = ZoneStatus(readHexDigit(s))
-	}
-	return status, nil
+	var zs ZoneStatusReply
+	err := zs.Decode(data)
+	return zs.Status, err
 }
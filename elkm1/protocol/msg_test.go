@@ -6,6 +6,9 @@ package protocol_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -35,6 +38,37 @@ func TestRealtime(t *testing.T) {
 	}
 }
 
+func TestRealtimeWrite(t *testing.T) {
+	when := time.Date(2024, 1, 11, 0, 0, 0, 0, time.Local)
+	roundTrip(t, "13rw000000511012400D5\r\n", &protocol.RealTimeWriteRequest{Time: when})
+
+	// The day of the week in the spec's example is wrong, it is ignored
+	// when decoding.
+	m, err := protocol.Decode([]byte("13rw305923111050500C0\r\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := m.(*protocol.RealTimeWriteRequest).Time, time.Date(2005, 5, 11, 23, 59, 30, 0, time.Local); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	ctx := context.Background()
+	ft, sess := newSession()
+	if err := protocol.SetTime(ctx, sess, when); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := protocol.SetTime(ctx, sess, time.Date(1999, 12, 31, 0, 0, 0, 0, time.Local)); err == nil {
+		t.Errorf("expected an error for an invalid year")
+	}
+	ctx = protocol.ContextWithVersion(ctx, protocol.Version{Major: 4, Minor: 2, Patch: 0})
+	if err := protocol.SetTime(ctx, sess, when); !errors.Is(err, protocol.ErrUnsupported) {
+		t.Errorf("unexpected or missing error: %v", err)
+	}
+	if got, want := strings.Join(ft.sent, ""), "13rw000000511012400D5\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
 func TestAsciiDescription(t *testing.T) {
 	var req protocol.Request
 	msg, resp := req.ZoneName(1)
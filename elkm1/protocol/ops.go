@@ -6,20 +6,131 @@ package protocol
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/cosnicolaou/automation/net/streamconn"
 )
 
-var (
-	request Request
-)
+// RealTimeRequest (rr) requests the M1's real time clock.
+type RealTimeRequest struct{}
+
+func (m *RealTimeRequest) Type() string             { return "rr" }
+func (m *RealTimeRequest) Encode() []byte           { return nil }
+func (m *RealTimeRequest) Decode(data []byte) error { return checkLen("rr", data, 0) }
+
+// RealTimeReply (RR) is the reply to a RealTimeRequest.
+type RealTimeReply struct {
+	Time time.Time
+	DST  bool
+}
+
+func (m *RealTimeReply) Type() string { return "RR" }
+
+func (m *RealTimeReply) Encode() []byte {
+	return encodeTime(m.Time, m.DST)
+}
+
+func (m *RealTimeReply) Decode(data []byte) error {
+	return decodeTime("RR", data, &m.Time, &m.DST)
+}
+
+// Heartbeat (XK) is sent by the M1 every 30 seconds and includes the
+// current time.
+type Heartbeat struct {
+	Time time.Time
+	DST  bool
+}
+
+func (m *Heartbeat) Type() string { return "XK" }
+
+func (m *Heartbeat) Encode() []byte {
+	return encodeTime(m.Time, m.DST)
+}
+
+func (m *Heartbeat) Decode(data []byte) error {
+	return decodeTime("XK", data, &m.Time, &m.DST)
+}
+
+// timeLen is the length of the time data in RR and XK messages, ie.
+// ssmmhhDddMMYYSCT.
+const timeLen = 16
+
+func decodeTime(what string, data []byte, t *time.Time, dst *bool) error {
+	if len(data) < timeLen {
+		return fmt.Errorf("unexpected response size for %v: got %v, expected %v", what, len(data), timeLen)
+	}
+	var err error
+	*t, *dst, err = ParseTime(data)
+	return err
+}
+
+func encodeTime(t time.Time, dst bool) []byte {
+	buf := make([]byte, 0, timeLen)
+	buf = appendDecInt(buf, t.Second(), 2)
+	buf = appendDecInt(buf, t.Minute(), 2)
+	buf = appendDecInt(buf, t.Hour(), 2)
+	buf = appendDecInt(buf, int(t.Weekday())+1, 1)
+	buf = appendDecInt(buf, t.Day(), 2)
+	buf = appendDecInt(buf, int(t.Month()), 2)
+	buf = appendDecInt(buf, t.Year()%100, 2)
+	if dst {
+		buf = append(buf, '1')
+	} else {
+		buf = append(buf, '0')
+	}
+	return append(buf, '1', '0') // 12 hour clock, mm/dd date display.
+}
+
+// RealTimeWriteRequest (rw) sets the M1's real time clock. The M1
+// does not reply to rw requests.
+type RealTimeWriteRequest struct {
+	Time time.Time
+}
+
+// realTimeWriteLen is the length of the time data in rw messages, ie.
+// ssmmhhDddMMYY.
+const realTimeWriteLen = 13
+
+func (m *RealTimeWriteRequest) Type() string { return "rw" }
 
+func (m *RealTimeWriteRequest) Encode() []byte {
+	return encodeTime(m.Time, false)[:realTimeWriteLen]
+}
+
+func (m *RealTimeWriteRequest) Decode(data []byte) error {
+	if err := checkLen("rw", data, realTimeWriteLen); err != nil {
+		return err
+	}
+	var f [7]int
+	for i, n := range []int{2, 2, 2, 1, 2, 2, 2} {
+		var err error
+		if f[i], data, err = readDecIntN(data, n); err != nil {
+			return err
+		}
+	}
+	// f[3], the day of the week, is implied by the date.
+	m.Time = time.Date(2000+f[6], time.Month(f[5]), f[4], f[2], f[1], f[0], 0, time.Local)
+	return nil
+}
+
+// GetTime returns the M1's real time clock, in the local time zone, and
+// whether daylight saving time is in effect.
 func GetTime(ctx context.Context, sess *streamconn.Session) (time.Time, bool, error) {
-	req, resp := request.RealTime()
-	data, err := rpc(ctx, sess, req, resp)
-	if err != nil {
+	var reply RealTimeReply
+	if err := call(ctx, sess, &RealTimeRequest{}, &reply); err != nil {
 		return time.Time{}, false, err
 	}
-	return ParseTime(data)
+	return reply.Time, reply.DST, nil
+}
+
+// SetTime sets the M1's real time clock to the wall clock time of t, ie.
+// the time in t's location. The M1 applies its own daylight saving time
+// setting and hence t should be in the time zone, standard or daylight
+// saving, that the M1 is configured to use.
+func SetTime(ctx context.Context, sess *streamconn.Session, t time.Time) error {
+	if y := t.Year(); y < 2000 || y > 2099 {
+		return fmt.Errorf("invalid year for the M1 real time clock: %v", y)
+	}
+	return send(ctx, sess, &RealTimeWriteRequest{Time: t})
 }
@@ -0,0 +1,193 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+const NumOutputs = 208
+
+// MaxOutputDuration is the longest time that an output can be turned on
+// for using an OutputOnRequest.
+const MaxOutputDuration = 65535 * time.Second
+
+// OutputOnRequest (cn) turns on an output, for the specified number of
+// seconds, or indefinitely if Seconds is zero.
+type OutputOnRequest struct {
+	Output  int
+	Seconds int
+}
+
+func (m *OutputOnRequest) Type() string { return "cn" }
+
+func (m *OutputOnRequest) Encode() []byte {
+	return appendDecInt(appendDecInt(nil, m.Output, 3), m.Seconds, 5)
+}
+
+func (m *OutputOnRequest) Decode(data []byte) error {
+	if err := checkLen("cn", data, 3+5); err != nil {
+		return err
+	}
+	var err error
+	if m.Output, data, err = readDecIntN(data, 3); err != nil {
+		return err
+	}
+	m.Seconds, _, err = readDecIntN(data, 5)
+	return err
+}
+
+// OutputOffRequest (cf) turns off an output.
+type OutputOffRequest struct {
+	Output int
+}
+
+func (m *OutputOffRequest) Type() string   { return "cf" }
+func (m *OutputOffRequest) Encode() []byte { return appendDecInt(nil, m.Output, 3) }
+
+func (m *OutputOffRequest) Decode(data []byte) error {
+	return decodeOutputNumber("cf", data, &m.Output)
+}
+
+// OutputToggleRequest (ct) toggles an output.
+type OutputToggleRequest struct {
+	Output int
+}
+
+func (m *OutputToggleRequest) Type() string   { return "ct" }
+func (m *OutputToggleRequest) Encode() []byte { return appendDecInt(nil, m.Output, 3) }
+
+func (m *OutputToggleRequest) Decode(data []byte) error {
+	return decodeOutputNumber("ct", data, &m.Output)
+}
+
+func decodeOutputNumber(what string, data []byte, output *int) error {
+	if err := checkLen(what, data, 3); err != nil {
+		return err
+	}
+	var err error
+	*output, _, err = readDecIntN(data, 3)
+	return err
+}
+
+type OutputStatusAll [NumOutputs]bool
+
+// OutputStatusRequest (cs) requests the status of all outputs.
+type OutputStatusRequest struct{}
+
+func (m *OutputStatusRequest) Type() string             { return "cs" }
+func (m *OutputStatusRequest) Encode() []byte           { return nil }
+func (m *OutputStatusRequest) Decode(data []byte) error { return checkLen("cs", data, 0) }
+
+// OutputStatusReply (CS) is the reply to an OutputStatusRequest.
+type OutputStatusReply struct {
+	Status OutputStatusAll
+}
+
+func (m *OutputStatusReply) Type() string { return "CS" }
+
+func (m *OutputStatusReply) Encode() []byte {
+	buf := make([]byte, NumOutputs)
+	for i, on := range m.Status {
+		buf[i] = boolDigit(on)
+	}
+	return buf
+}
+
+func (m *OutputStatusReply) Decode(data []byte) error {
+	if err := checkLen("output status", data, NumOutputs); err != nil {
+		return err
+	}
+	for i, s := range data {
+		m.Status[i] = s == '1'
+	}
+	return nil
+}
+
+// OutputChange (CC) is sent by the M1, if so configured, whenever
+// the state of an output changes.
+type OutputChange struct {
+	Output int
+	On     bool
+}
+
+func (m *OutputChange) Type() string { return "CC" }
+
+func (m *OutputChange) Encode() []byte {
+	return append(appendDecInt(nil, m.Output, 3), boolDigit(m.On))
+}
+
+func (m *OutputChange) Decode(data []byte) error {
+	if err := checkLen("output change", data, 4); err != nil {
+		return err
+	}
+	output, data, err := readDecIntN(data, 3)
+	if err != nil {
+		return err
+	}
+	m.Output, m.On = output, data[0] == '1'
+	return nil
+}
+
+func boolDigit(v bool) byte {
+	if v {
+		return '1'
+	}
+	return '0'
+}
+
+func validateOutput(output int) error {
+	if output < 1 || output > NumOutputs {
+		return fmt.Errorf("invalid output number: %v", output)
+	}
+	return nil
+}
+
+// OutputOn turns on the specified output for the specified duration, or
+// indefinitely if the duration is zero. The M1's timer has a resolution of
+// one second and hence durations that are not a whole number of seconds
+// are rejected rather than being truncated, which for durations of less
+// than one second would leave the output on indefinitely.
+func OutputOn(ctx context.Context, sess *streamconn.Session, output int, duration time.Duration) error {
+	if err := validateOutput(output); err != nil {
+		return err
+	}
+	if duration < 0 || duration > MaxOutputDuration {
+		return fmt.Errorf("invalid output duration: %v, must be between 0 and %v", duration, MaxOutputDuration)
+	}
+	if duration%time.Second != 0 {
+		return fmt.Errorf("invalid output duration: %v, must be a whole number of seconds", duration)
+	}
+	return send(ctx, sess, &OutputOnRequest{Output: output, Seconds: int(duration / time.Second)})
+}
+
+// OutputOff turns off the specified output.
+func OutputOff(ctx context.Context, sess *streamconn.Session, output int) error {
+	if err := validateOutput(output); err != nil {
+		return err
+	}
+	return send(ctx, sess, &OutputOffRequest{Output: output})
+}
+
+// OutputToggle toggles the specified output.
+func OutputToggle(ctx context.Context, sess *streamconn.Session, output int) error {
+	if err := validateOutput(output); err != nil {
+		return err
+	}
+	return send(ctx, sess, &OutputToggleRequest{Output: output})
+}
+
+// GetOutputStatusAll returns the on/off status of all outputs.
+func GetOutputStatusAll(ctx context.Context, sess *streamconn.Session) (OutputStatusAll, error) {
+	var reply OutputStatusReply
+	if err := call(ctx, sess, &OutputStatusRequest{}, &reply); err != nil {
+		return OutputStatusAll{}, err
+	}
+	return reply.Status, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestOutputs(t *testing.T) {
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"09cf00200DC\r\n", &protocol.OutputOffRequest{Output: 2}},
+		{"0Ecn0010001000D8\r\n", &protocol.OutputOnRequest{Output: 1, Seconds: 10}},
+		{"09ct00200CE\r\n", &protocol.OutputToggleRequest{Output: 2}},
+		{"06cs0064\r\n", &protocol.OutputStatusRequest{}},
+		{"0ACC003100E5\r\n", &protocol.OutputChange{Output: 3, On: true}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	ctx := context.Background()
+	ft, sess := newSession()
+	defer sess.Release()
+	if err := protocol.OutputOn(ctx, sess, 1, 10*time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := strings.Join(ft.sent, ""), "0Ecn0010001000D8\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := protocol.OutputOn(ctx, sess, 209, 0); err == nil {
+		t.Errorf("expected an error for an invalid output")
+	}
+	for _, d := range []time.Duration{
+		protocol.MaxOutputDuration + time.Second,
+		-time.Second,
+		500 * time.Millisecond,
+		1500 * time.Millisecond,
+	} {
+		if err := protocol.OutputOn(ctx, sess, 1, d); err == nil {
+			t.Errorf("%v: expected an error for an invalid duration", d)
+		}
+	}
+	if got, want := len(ft.sent), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+// Message is implemented by all of the M1 ASCII messages supported by
+// this package. Requests sent to the M1 use lower case types, eg. "rr",
+// and the replies and unsolicited messages it sends use upper case types,
+// eg. "RR".
+type Message interface {
+	// Type returns the two character message type.
+	Type() string
+	// Encode returns the data portion of the message, ie. excluding the
+	// length, type, reserved bytes, checksum and terminator.
+	Encode() []byte
+	// Decode parses the data portion of the message.
+	Decode(data []byte) error
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]func() Message{}
+)
+
+// Register registers a factory for the message type returned by the
+// factory's messages. It panics if the type is not two characters long
+// or has already been registered.
+func Register(factory func() Message) {
+	typ := factory().Type()
+	if len(typ) != 2 {
+		panic(fmt.Sprintf("invalid message type: %q", typ))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[typ]; ok {
+		panic(fmt.Sprintf("message type %q is already registered", typ))
+	}
+	registry[typ] = factory
+}
+
+// Registered returns the sorted list of registered message types.
+func Registered() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	types := make([]string, 0, len(registry))
+	for k := range registry {
+		types = append(types, k)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewMessage returns a new, zero valued, instance of the message registered
+// for typ, or nil if there is none.
+func NewMessage(typ string) Message {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if f, ok := registry[typ]; ok {
+		return f()
+	}
+	return nil
+}
+
+// UnknownMessage is returned when decoding a message for which there
+// is no registered type.
+type UnknownMessage struct {
+	MessageType string
+	Data        []byte
+}
+
+func (m *UnknownMessage) Type() string { return m.MessageType }
+
+func (m *UnknownMessage) Encode() []byte { return m.Data }
+
+func (m *UnknownMessage) Decode(data []byte) error {
+	m.Data = data
+	return nil
+}
+
+// Message decodes the frame using the message type registered for it, an
+// UnknownMessage is returned for unregistered types.
+func (f Frame) Message() (Message, error) {
+	typ := string([]byte{f.Type, f.SubType})
+	m := NewMessage(typ)
+	if m == nil {
+		m = &UnknownMessage{MessageType: typ}
+	}
+	if err := m.Decode(f.Data); err != nil {
+		return nil, fmt.Errorf("%v: %w", typ, err)
+	}
+	return m, nil
+}
+
+// Decode decodes a complete message, including its length, checksum and
+// terminator, using the registered message types.
+func Decode(msg []byte) (Message, error) {
+	var resp Response
+	typ, subtype, data, err := resp.Decode(msg)
+	if err != nil {
+		return nil, err
+	}
+	return Frame{Type: typ, SubType: subtype, Data: data}.Message()
+}
+
+// Encode returns the complete message, including its length, checksum and
+// terminator, for m.
+func Encode(m Message) []byte {
+	typ := m.Type()
+	return formatMessage(typ[0], typ[1], m.Encode())
+}
+
+// call sends req and decodes the reply, whose type is given by reply.Type(),
+// into reply.
+func call(ctx context.Context, sess *streamconn.Session, req, reply Message) error {
+	if err := checkSupported(ctx, req); err != nil {
+		return err
+	}
+	data, err := rpc(ctx, sess, Encode(req), responseFor(reply))
+	if err != nil {
+		return err
+	}
+	return reply.Decode(data)
+}
+
+// callSensitive is like call except that the request is not logged,
+// it is used for requests that contain user codes.
+func callSensitive(ctx context.Context, sess *streamconn.Session, req, reply Message) error {
+	if err := checkSupported(ctx, req); err != nil {
+		return err
+	}
+	data, err := DispatcherFromContext(ctx).call(ctx, sess, responseFor(reply), func() {
+		sess.SendSensitive(ctx, Encode(req))
+	})
+	if err != nil {
+		return err
+	}
+	return reply.Decode(data)
+}
+
+// send sends req, for which the M1 does not send a reply.
+func send(ctx context.Context, sess *streamconn.Session, req Message) error {
+	if err := checkSupported(ctx, req); err != nil {
+		return err
+	}
+	sess.Send(ctx, Encode(req))
+	return sess.Err()
+}
+
+func init() {
+	for _, f := range []func() Message{
+		func() Message { return &RealTimeRequest{} },
+		func() Message { return &RealTimeReply{} },
+		func() Message { return &RealTimeWriteRequest{} },
+		func() Message { return &Heartbeat{} },
+		func() Message { return &ZoneDefinitionsRequest{} },
+		func() Message { return &ZoneDefinitionsReply{} },
+		func() Message { return &ZoneStatusRequest{} },
+		func() Message { return &ZoneStatusReply{} },
+		func() Message { return &ZoneChange{} },
+		func() Message { return &AlarmByZoneRequest{} },
+		func() Message { return &AlarmByZoneReply{} },
+		func() Message { return &AlarmReport{} },
+		func() Message { return &AlarmReportAck{} },
+		func() Message { return &AlarmReportFail{} },
+		func() Message { return &TextDescriptionRequest{} },
+		func() Message { return &TextDescriptionReply{} },
+		func() Message { return &ArmingStatusRequest{} },
+		func() Message { return &ArmingStatusReply{} },
+		func() Message { return &EntryExitTimer{} },
+		func() Message { return &OutputOnRequest{} },
+		func() Message { return &OutputOffRequest{} },
+		func() Message { return &OutputToggleRequest{} },
+		func() Message { return &OutputStatusRequest{} },
+		func() Message { return &OutputStatusReply{} },
+		func() Message { return &OutputChange{} },
+		func() Message { return &TaskActivationRequest{} },
+		func() Message { return &TaskChange{} },
+		func() Message { return &ZoneBypassRequest{} },
+		func() Message { return &ZoneBypassReply{} },
+		func() Message { return &ZoneTriggerRequest{} },
+		func() Message { return &ZoneVoltageRequest{} },
+		func() Message { return &ZoneVoltageReply{} },
+		func() Message { return &ThermostatDataRequest{} },
+		func() Message { return &ThermostatData{} },
+		func() Message { return &ThermostatSetRequest{} },
+		func() Message { return &TemperatureRequest{} },
+		func() Message { return &TemperatureReply{} },
+		func() Message { return &TemperaturesRequest{} },
+		func() Message { return &TemperaturesReply{} },
+		func() Message { return &LightingControlRequest{} },
+		func() Message { return &LightingChange{} },
+		func() Message { return &LightingOffRequest{} },
+		func() Message { return &LightingOnRequest{} },
+		func() Message { return &LightingToggleRequest{} },
+		func() Message { return &LightingStatusRequest{} },
+		func() Message { return &LightingStatusReply{} },
+		func() Message { return &CounterRequest{} },
+		func() Message { return &CounterWriteRequest{} },
+		func() Message { return &CounterReply{} },
+		func() Message { return &CustomValueRequest{} },
+		func() Message { return &CustomValuesRequest{} },
+		func() Message { return &CustomValueReply{} },
+		func() Message { return &CustomValueWriteRequest{} },
+		func() Message { return &LogRequest{} },
+		func() Message { return &LogEntry{} },
+		func() Message { return &LogWriteRequest{} },
+		func() Message { return &VersionRequest{} },
+		func() Message { return &VersionReply{} },
+		func() Message { return &DisplayMessageRequest{} },
+		func() Message { return &KeypadAreasRequest{} },
+		func() Message { return &KeypadAreasReply{} },
+		func() Message { return &KeypadChange{} },
+		func() Message { return &KeypadStatusRequest{} },
+		func() Message { return &FunctionKeyRequest{} },
+		func() Message { return &FunctionKeyReply{} },
+		func() Message { return &TroubleStatusRequest{} },
+		func() Message { return &TroubleStatus{} },
+		func() Message { return &SpeakWordRequest{} },
+		func() Message { return &SpeakPhraseRequest{} },
+	} {
+		Register(f)
+	}
+	for _, l := range ArmingLevels() {
+		Register(func() Message { return &ArmRequest{Level: l} })
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+// decodes checks that msg decodes to want and returns the decoded message,
+// or nil if it could not be decoded.
+func decodes(t *testing.T, msg string, want protocol.Message) protocol.Message {
+	t.Helper()
+	m, err := protocol.Decode([]byte(msg))
+	if err != nil {
+		t.Errorf("%q: unexpected error: %v", msg, err)
+		return nil
+	}
+	if got := m; !reflect.DeepEqual(got, want) {
+		t.Errorf("%q: got %#v, want %#v", msg, got, want)
+	}
+	return m
+}
+
+// roundTrip checks that msg decodes to want and that the decoded message
+// encodes back to msg.
+func roundTrip(t *testing.T, msg string, want protocol.Message) {
+	t.Helper()
+	m := decodes(t, msg, want)
+	if m == nil {
+		return
+	}
+	if got := string(protocol.Encode(m)); got != msg {
+		t.Errorf("got %q, want %q", got, msg)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"06rr0056\r\n", &protocol.RealTimeRequest{}},
+		{"0Bsd000010066\r\n", &protocol.TextDescriptionRequest{Kind: protocol.ZoneDescription, Index: 1}},
+		{"1BSD01001Front DoorKeypad0089\r\n", &protocol.TextDescriptionReply{
+			Kind: 1, Index: 1, Name: "Front DoorKeypad"}},
+		{"0AZC002900C7\r\n", &protocol.ZoneChange{Zone: 2, Status: 9}},
+		{"08zz0000E4\r\n", &protocol.UnknownMessage{MessageType: "zz", Data: []byte("00")}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	// The day of the week and clock modes are not round tripped.
+	decodes(t, "16RR0059107251205110006E\r\n", &protocol.RealTimeReply{
+		Time: time.Date(2005, 12, 25, 10, 59, 0, 0, time.Local), DST: true})
+
+	if _, err := protocol.Decode([]byte("0AZC00X900A1\r\n")); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	for _, typ := range []string{"rr", "RR", "XK", "zd", "ZD", "zs", "ZS", "ZC", "sd", "SD"} {
+		if m := protocol.NewMessage(typ); m == nil || m.Type() != typ {
+			t.Errorf("%v: not registered", typ)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+)
+
+// EventQualifier is the Contact ID event qualifier, ie. the first digit of
+// an alarm report's alarm code.
+type EventQualifier int
+
+const (
+	NewEvent           EventQualifier = 1
+	RestoreEvent       EventQualifier = 3
+	PreviouslyReported EventQualifier = 6
+)
+
+var eventQualifierNames = map[EventQualifier]string{
+	NewEvent:           "new",
+	RestoreEvent:       "restore",
+	PreviouslyReported: "previously-reported",
+}
+
+func (q EventQualifier) String() string {
+	if n, ok := eventQualifierNames[q]; ok {
+		return n
+	}
+	return fmt.Sprintf("UnknownEventQualifier(%v)", int(q))
+}
+
+// AlarmReport (AR) is sent by the M1 to an IP communicator, such as the
+// M1XEP, to report an alarm to a central station, M1 Ver. 4.2.8 and later.
+// The receipt of every report must be acknowledged by sending either an
+// AlarmReportAck or an AlarmReportFail.
+type AlarmReport struct {
+	// Account is the six digit account number, it is kept as a string
+	// since Contact ID account numbers may contain leading zeros.
+	Account   string
+	Qualifier EventQualifier
+	// Event is the three digit Contact ID event code, eg. 134 for a
+	// burglary entry/exit alarm.
+	Event int
+	// Area is the group/partition number.
+	Area int
+	// Zone is the zone or user number.
+	Zone int
+	// Slot is the telephone number/IP address slot to report the alarm to.
+	Slot int
+}
+
+// alarmReportAccountLen is the length of the account number in an AR message.
+const alarmReportAccountLen = 6
+
+func (m *AlarmReport) Type() string { return "AR" }
+
+func (m *AlarmReport) Encode() []byte {
+	buf := append([]byte(nil), m.Account...)
+	buf = appendDecInt(buf, int(m.Qualifier), 1)
+	buf = appendDecInt(buf, m.Event, 3)
+	buf = appendDecInt(buf, m.Area, 2)
+	buf = appendDecInt(buf, m.Zone, 3)
+	return appendDecInt(buf, m.Slot, 1)
+}
+
+func (m *AlarmReport) Decode(data []byte) error {
+	if err := checkLen("alarm report", data, alarmReportAccountLen+10); err != nil {
+		return err
+	}
+	m.Account = string(data[:alarmReportAccountLen])
+	data = data[alarmReportAccountLen:]
+	var qualifier int
+	var err error
+	for _, f := range []struct {
+		v *int
+		n int
+	}{
+		{&qualifier, 1},
+		{&m.Event, 3},
+		{&m.Area, 2},
+		{&m.Zone, 3},
+		{&m.Slot, 1},
+	} {
+		if *f.v, data, err = readDecIntN(data, f.n); err != nil {
+			return fmt.Errorf("invalid alarm report: %w", err)
+		}
+	}
+	m.Qualifier = EventQualifier(qualifier)
+	return nil
+}
+
+// AlarmCode returns the four digit Contact ID alarm code, ie. the
+// qualifier followed by the event code.
+func (m *AlarmReport) AlarmCode() string {
+	return string(appendDecInt(appendDecInt(nil, int(m.Qualifier), 1), m.Event, 3))
+}
+
+func (m AlarmReport) String() string {
+	return fmt.Sprintf("account %v, event %v (%v), area %v, zone %v", m.Account, m.Event, m.Qualifier, m.Area, m.Zone)
+}
+
+// AlarmReportAck (ar) acknowledges the receipt of an AlarmReport.
+type AlarmReportAck struct{}
+
+func (m *AlarmReportAck) Type() string             { return "ar" }
+func (m *AlarmReportAck) Encode() []byte           { return nil }
+func (m *AlarmReportAck) Decode(data []byte) error { return checkLen("ar", data, 0) }
+
+// AlarmReportFail (ax) tells the M1 that an AlarmReport could not be
+// delivered.
+type AlarmReportFail struct{}
+
+func (m *AlarmReportFail) Type() string             { return "ax" }
+func (m *AlarmReportFail) Encode() []byte           { return nil }
+func (m *AlarmReportFail) Decode(data []byte) error { return checkLen("ax", data, 0) }
+
+// AcknowledgeAlarmReport tells the M1 that the alarm report being
+// delivered to the handler called with ctx was successfully received.
+func AcknowledgeAlarmReport(ctx context.Context) error {
+	return Reply(ctx, &AlarmReportAck{})
+}
+
+// FailAlarmReport tells the M1 that the alarm report being delivered to
+// the handler called with ctx could not be delivered.
+func FailAlarmReport(ctx context.Context) error {
+	return Reply(ctx, &AlarmReportFail{})
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestAlarmReport(t *testing.T) {
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"16AR12345611340100110085\r\n", &protocol.AlarmReport{
+			Account: "123456", Qualifier: protocol.NewEvent, Event: 134, Area: 1, Zone: 1, Slot: 1}},
+		{"16AR0012343601020122008B\r\n", &protocol.AlarmReport{
+			Account: "001234", Qualifier: protocol.RestoreEvent, Event: 601, Area: 2, Zone: 12, Slot: 2}},
+		{"06ar0067\r\n", &protocol.AlarmReportAck{}},
+		{"06ax0061\r\n", &protocol.AlarmReportFail{}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	ar := protocol.AlarmReport{Qualifier: protocol.NewEvent, Event: 134}
+	if got, want := ar.AlarmCode(), "1134"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := protocol.PreviouslyReported.String(), "previously-reported"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	var ar2 protocol.AlarmReport
+	if err := ar2.Decode([]byte("1234561x340100110")); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestAlarmReportAcknowledge(t *testing.T) {
+	ctx := context.Background()
+	ft, sess := newSession("16AR12345611340100110085\r\n", "16AR0012343601020122008B\r\n")
+	defer sess.Release()
+	d := protocol.NewDispatcher()
+	d.Handle('A', 'R', func(ctx context.Context, f protocol.Frame) {
+		var ar protocol.AlarmReport
+		if err := ar.Decode(f.Data); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if ar.Qualifier == protocol.NewEvent {
+			_ = protocol.AcknowledgeAlarmReport(ctx)
+			return
+		}
+		_ = protocol.FailAlarmReport(ctx)
+	})
+	if err := d.Listen(ctx, sess); err != io.EOF {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := strings.Join(ft.sent, ""), "06ar0067\r\n06ax0061\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := protocol.AcknowledgeAlarmReport(ctx); err == nil {
+		t.Errorf("expected an error")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+const NumTasks = 32
+
+// TaskActivationRequest (tn) activates a task.
+type TaskActivationRequest struct {
+	Task int
+}
+
+func (m *TaskActivationRequest) Type() string   { return "tn" }
+func (m *TaskActivationRequest) Encode() []byte { return appendDecInt(nil, m.Task, 3) }
+
+func (m *TaskActivationRequest) Decode(data []byte) error {
+	if err := checkLen("tn", data, 3); err != nil {
+		return err
+	}
+	var err error
+	m.Task, _, err = readDecIntN(data, 3)
+	return err
+}
+
+// TaskChange (TC) is sent by the M1, if so configured, whenever a task
+// is activated.
+type TaskChange struct {
+	Task int
+}
+
+func (m *TaskChange) Type() string   { return "TC" }
+func (m *TaskChange) Encode() []byte { return append(appendDecInt(nil, m.Task, 3), '0') }
+
+func (m *TaskChange) Decode(data []byte) error {
+	if err := checkLen("task change", data, 4); err != nil {
+		return err
+	}
+	var err error
+	m.Task, _, err = readDecIntN(data, 3)
+	return err
+}
+
+// ActivateTask activates the specified task.
+func ActivateTask(ctx context.Context, sess *streamconn.Session, task int) error {
+	if task < 1 || task > NumTasks {
+		return fmt.Errorf("invalid task number: %v", task)
+	}
+	return send(ctx, sess, &TaskActivationRequest{Task: task})
+}
+
+// FindTask returns the number of the task with the specified name,
+// ignoring case and trailing spaces.
+func FindTask(ctx context.Context, sess *streamconn.Session, name string) (int, error) {
+	task := 0
+	err := WalkNames(ctx, sess, TaskDescription, func(d Description) bool {
+		if strings.EqualFold(strings.TrimSpace(d.Name), name) {
+			task = d.Index
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if task == 0 {
+		return 0, fmt.Errorf("no task named %q", name)
+	}
+	return task, nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestTasks(t *testing.T) {
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"09tn00100C4\r\n", &protocol.TaskActivationRequest{Task: 1}},
+		{"0ATC001000D7\r\n", &protocol.TaskChange{Task: 1}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	ctx := context.Background()
+	ft, sess := newSession(
+		"1BSD05001Lights Off      003A\r\n",
+		"1BSD05003Open Gate       008B\r\n",
+	)
+	defer sess.Release()
+	task, err := protocol.FindTask(ctx, sess, "open gate")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := task, 3; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(ft.sent), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	_, sess = newSession("1BSD05000                00A1\r\n")
+	defer sess.Release()
+	if _, err := protocol.FindTask(ctx, sess, "open gate"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
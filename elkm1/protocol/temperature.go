@@ -0,0 +1,214 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+// NumTemperatureDevices is the number of devices in each temperature group.
+const NumTemperatureDevices = 16
+
+// ErrNoTemperature is returned by GetTemperature for devices that are
+// not installed or are not reporting a temperature.
+var ErrNoTemperature = errors.New("temperature is not available")
+
+// TemperatureGroup identifies the type of device that a temperature is
+// read from.
+type TemperatureGroup byte
+
+const (
+	// TemperatureProbeGroup refers to the temperature probes connected
+	// to zones 1 to 16.
+	TemperatureProbeGroup TemperatureGroup = iota
+	KeypadGroup
+	ThermostatGroup
+)
+
+var (
+	temperatureGroupNames = []string{
+		"probe",
+		"keypad",
+		"thermostat",
+	}
+)
+
+func (g TemperatureGroup) String() string {
+	if int(g) >= len(temperatureGroupNames) {
+		return fmt.Sprintf("UnknownTemperatureGroup(%v)", int(g))
+	}
+	return temperatureGroupNames[g]
+}
+
+// ParseTemperatureGroup parses the names returned by TemperatureGroup.String.
+func ParseTemperatureGroup(group string) (TemperatureGroup, error) {
+	for i, n := range temperatureGroupNames {
+		if n == group {
+			return TemperatureGroup(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown temperature group: %q", group)
+}
+
+// offset returns the value that the M1 adds to the temperatures it
+// reports for devices in the group.
+func (g TemperatureGroup) offset() int {
+	switch g {
+	case TemperatureProbeGroup:
+		return 60
+	case KeypadGroup:
+		return 40
+	}
+	return 0
+}
+
+// Valid returns true if degrees is a valid temperature for a device in
+// the group, devices that are not present, or are not reporting a
+// temperature, report a value of zero before the offset is removed.
+func (g TemperatureGroup) Valid(degrees int) bool {
+	return degrees > -g.offset()
+}
+
+// TemperatureRequest (st) requests the temperature of a single device,
+// M1 Ver. 4.2.8 and later.
+type TemperatureRequest struct {
+	Group  TemperatureGroup
+	Device int
+}
+
+func (m *TemperatureRequest) Type() string { return "st" }
+
+func (m *TemperatureRequest) Encode() []byte {
+	return appendDecInt([]byte{'0' + byte(m.Group)}, m.Device, 2)
+}
+
+func (m *TemperatureRequest) Decode(data []byte) error {
+	if err := checkLen("st", data, 1+2); err != nil {
+		return err
+	}
+	m.Group = TemperatureGroup(data[0] - '0')
+	var err error
+	m.Device, _, err = readDecIntN(data[1:], 2)
+	return err
+}
+
+// TemperatureReply (ST) is the reply to a TemperatureRequest and is also
+// sent whenever a temperature changes. Temperature is in degrees
+// Fahrenheit with the group's offset removed.
+type TemperatureReply struct {
+	Group       TemperatureGroup
+	Device      int
+	Temperature int
+}
+
+func (m *TemperatureReply) Type() string { return "ST" }
+
+func (m *TemperatureReply) Encode() []byte {
+	buf := appendDecInt([]byte{'0' + byte(m.Group)}, m.Device, 2)
+	return appendDecInt(buf, m.Temperature+m.Group.offset(), 3)
+}
+
+func (m *TemperatureReply) Decode(data []byte) error {
+	if err := checkLen("temperature", data, 1+2+3); err != nil {
+		return err
+	}
+	m.Group = TemperatureGroup(data[0] - '0')
+	var err error
+	if m.Device, data, err = readDecIntN(data[1:], 2); err != nil {
+		return err
+	}
+	if m.Temperature, _, err = readDecIntN(data, 3); err != nil {
+		return err
+	}
+	m.Temperature -= m.Group.offset()
+	return nil
+}
+
+// TemperaturesRequest (lw) requests the temperatures of all keypads and
+// zone temperature probes, M1 Ver. 4.3.4 and later.
+type TemperaturesRequest struct{}
+
+func (m *TemperaturesRequest) Type() string   { return "lw" }
+func (m *TemperaturesRequest) Encode() []byte { return nil }
+
+func (m *TemperaturesRequest) Decode(data []byte) error {
+	return checkLen("lw", data, 0)
+}
+
+// TemperaturesReply (LW) is the reply to a TemperaturesRequest.
+// Temperatures are in degrees Fahrenheit with the group's offset
+// removed, use TemperatureGroup.Valid to determine if a device is
+// reporting a temperature.
+type TemperaturesReply struct {
+	Keypads [NumTemperatureDevices]int
+	Probes  [NumTemperatureDevices]int
+}
+
+func (m *TemperaturesReply) Type() string { return "LW" }
+
+func (m *TemperaturesReply) Encode() []byte {
+	buf := make([]byte, 0, 2*NumTemperatureDevices*3)
+	for _, t := range m.Keypads {
+		buf = appendDecInt(buf, t+KeypadGroup.offset(), 3)
+	}
+	for _, t := range m.Probes {
+		buf = appendDecInt(buf, t+TemperatureProbeGroup.offset(), 3)
+	}
+	return buf
+}
+
+func (m *TemperaturesReply) Decode(data []byte) error {
+	if err := checkLen("temperatures", data, 2*NumTemperatureDevices*3); err != nil {
+		return err
+	}
+	var err error
+	for i := range m.Keypads {
+		if m.Keypads[i], data, err = readDecIntN(data, 3); err != nil {
+			return err
+		}
+		m.Keypads[i] -= KeypadGroup.offset()
+	}
+	for i := range m.Probes {
+		if m.Probes[i], data, err = readDecIntN(data, 3); err != nil {
+			return err
+		}
+		m.Probes[i] -= TemperatureProbeGroup.offset()
+	}
+	return nil
+}
+
+// GetTemperature returns the temperature of the specified device, an
+// error is returned if the device is not reporting a temperature.
+func GetTemperature(ctx context.Context, sess *streamconn.Session, group TemperatureGroup, device int) (int, error) {
+	if group > ThermostatGroup {
+		return 0, fmt.Errorf("invalid temperature group: %v", group)
+	}
+	if device < 1 || device > NumTemperatureDevices {
+		return 0, fmt.Errorf("invalid %v number: %v", group, device)
+	}
+	var reply TemperatureReply
+	if err := call(ctx, sess, &TemperatureRequest{Group: group, Device: device}, &reply); err != nil {
+		return 0, err
+	}
+	if reply.Group != group || reply.Device != device {
+		return 0, fmt.Errorf("unexpected temperature device: got %v %v, expected %v %v", reply.Group, reply.Device, group, device)
+	}
+	if !group.Valid(reply.Temperature) {
+		return 0, fmt.Errorf("%v %v: %w", group, device, ErrNoTemperature)
+	}
+	return reply.Temperature, nil
+}
+
+// GetTemperatures returns the temperatures of all keypads and zone
+// temperature probes.
+func GetTemperatures(ctx context.Context, sess *streamconn.Session) (TemperaturesReply, error) {
+	var reply TemperaturesReply
+	err := call(ctx, sess, &TemperaturesRequest{}, &reply)
+	return reply, err
+}
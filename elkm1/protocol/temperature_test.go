@@ -0,0 +1,63 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestTemperatures(t *testing.T) {
+	var lw protocol.TemperaturesReply
+	for i := range lw.Keypads {
+		lw.Keypads[i] = -40
+		lw.Probes[i] = -60
+	}
+	lw.Keypads[0], lw.Keypads[1], lw.Probes[14] = 68, 69, 70
+
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"09st00100BF\r\n", &protocol.TemperatureRequest{Group: protocol.TemperatureProbeGroup, Device: 1}},
+		{"09st10100BE\r\n", &protocol.TemperatureRequest{Group: protocol.KeypadGroup, Device: 1}},
+		{"09st20100BD\r\n", &protocol.TemperatureRequest{Group: protocol.ThermostatGroup, Device: 1}},
+		{"0CST001135005C\r\n", &protocol.TemperatureReply{Group: protocol.TemperatureProbeGroup, Device: 1, Temperature: 75}},
+		{"0CST102105005D\r\n", &protocol.TemperatureReply{Group: protocol.KeypadGroup, Device: 2, Temperature: 65}},
+		{"0CST201072005A\r\n", &protocol.TemperatureReply{Group: protocol.ThermostatGroup, Device: 1, Temperature: 72}},
+		{"06lw0057\r\n", &protocol.TemperaturesRequest{}},
+		{"66LW108109" + strings.Repeat("000", 28) + "130000007A\r\n", &lw},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	if protocol.KeypadGroup.Valid(lw.Keypads[2]) || !protocol.KeypadGroup.Valid(lw.Keypads[0]) {
+		t.Errorf("incorrect validity for keypad temperatures")
+	}
+
+	ctx := context.Background()
+	ft, sess := newSession("0CST102105005D\r\n", "0CST2010000063\r\n")
+	defer sess.Release()
+	temp, err := protocol.GetTemperature(ctx, sess, protocol.KeypadGroup, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := temp, 65; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := strings.Join(ft.sent, ""), "09st10200BD\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := protocol.GetTemperature(ctx, sess, protocol.ThermostatGroup, 1); !errors.Is(err, protocol.ErrNoTemperature) {
+		t.Errorf("expected an error for an unavailable temperature: %v", err)
+	}
+	if _, err := protocol.GetTemperature(ctx, sess, protocol.ThermostatGroup, 17); err == nil {
+		t.Errorf("expected an error for an invalid device")
+	}
+}
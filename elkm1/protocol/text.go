@@ -0,0 +1,230 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+// DescriptionType identifies the kind of entity whose text description
+// is requested by a TextDescriptionRequest.
+type DescriptionType int
+
+const (
+	ZoneDescription DescriptionType = iota
+	AreaDescription
+	UserDescription
+	KeypadDescription
+	OutputDescription
+	TaskDescription
+	TelephoneDescription
+	LightDescription
+	AlarmDurationDescription
+	CustomSettingDescription
+	CounterDescription
+	ThermostatDescription
+	// The function key descriptions are indexed by keypad number.
+	FunctionKey1Description
+	FunctionKey2Description
+	FunctionKey3Description
+	FunctionKey4Description
+	FunctionKey5Description
+	FunctionKey6Description
+	AudioZoneDescription
+	AudioSourceDescription
+)
+
+var descriptionTypes = []struct {
+	name string
+	max  int
+}{
+	{"zone", NumZones},
+	{"area", NumAreas},
+	{"user", 199},
+	{"keypad", 16},
+	{"output", 64}, // outputs 65 to 208 have no names.
+	{"task", NumTasks},
+	{"telephone", 8},
+	{"light", NumLightingDevices},
+	{"alarm-duration", 12},
+	{"custom-setting", NumCustomValues},
+	{"counter", NumCounters},
+	{"thermostat", NumThermostats},
+	{"function-key-1", 16},
+	{"function-key-2", 16},
+	{"function-key-3", 16},
+	{"function-key-4", 16},
+	{"function-key-5", 16},
+	{"function-key-6", 16},
+	{"audio-zone", 18},
+	{"audio-source", 12},
+}
+
+func (d DescriptionType) String() string {
+	if d < 0 || int(d) >= len(descriptionTypes) {
+		return fmt.Sprintf("UnknownDescriptionType(%v)", int(d))
+	}
+	return descriptionTypes[d].name
+}
+
+// Max returns the highest index for which the M1 stores a description
+// of this type.
+func (d DescriptionType) Max() int {
+	if d < 0 || int(d) >= len(descriptionTypes) {
+		return 0
+	}
+	return descriptionTypes[d].max
+}
+
+// DescriptionTypes returns all of the supported description types.
+func DescriptionTypes() []DescriptionType {
+	types := make([]DescriptionType, len(descriptionTypes))
+	for i := range descriptionTypes {
+		types[i] = DescriptionType(i)
+	}
+	return types
+}
+
+// ParseDescriptionType parses the names returned by DescriptionType.String.
+func ParseDescriptionType(kind string) (DescriptionType, error) {
+	for i, dt := range descriptionTypes {
+		if dt.name == kind {
+			return DescriptionType(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown description type: %q", kind)
+}
+
+// TextDescriptionRequest (sd) requests the text description of an entity.
+type TextDescriptionRequest struct {
+	Kind  DescriptionType
+	Index int
+}
+
+func (m *TextDescriptionRequest) Type() string { return "sd" }
+
+func (m *TextDescriptionRequest) Encode() []byte {
+	return appendDecInt(appendDecInt(nil, int(m.Kind), 2), m.Index, 3)
+}
+
+func (m *TextDescriptionRequest) Decode(data []byte) error {
+	if err := checkLen("sd", data, 2+3); err != nil {
+		return err
+	}
+	kind, data, err := readDecIntN(data, 2)
+	if err != nil {
+		return err
+	}
+	m.Kind = DescriptionType(kind)
+	m.Index, _, err = readDecIntN(data, 3)
+	return err
+}
+
+// descriptionLen is the fixed length of a text description.
+const descriptionLen = 16
+
+// TextDescriptionReply (SD) is the reply to a TextDescriptionRequest.
+// The M1 may set the high bit of the first character of the name to
+// indicate that it is displayed on the keypads, this bit is cleared
+// when the reply is decoded.
+type TextDescriptionReply struct {
+	Kind  DescriptionType
+	Index int
+	Name  string
+}
+
+func (m *TextDescriptionReply) Type() string { return "SD" }
+
+func (m *TextDescriptionReply) Encode() []byte {
+	buf := appendDecInt(appendDecInt(nil, int(m.Kind), 2), m.Index, 3)
+	return append(buf, fmt.Sprintf("%-16.16s", m.Name)...)
+}
+
+func (m *TextDescriptionReply) Decode(data []byte) error {
+	if err := checkLen("text description", data, 2+3+descriptionLen); err != nil {
+		return err
+	}
+	kind, data, err := readDecIntN(data, 2)
+	if err != nil {
+		return err
+	}
+	m.Kind = DescriptionType(kind)
+	if m.Index, data, err = readDecIntN(data, 3); err != nil {
+		return err
+	}
+	name := slices.Clone(data)
+	name[0] &= 0x7f
+	m.Name = string(name)
+	return nil
+}
+
+// GetName returns the name of the specified entity, or an empty string
+// if it has no name.
+func GetName(ctx context.Context, sess *streamconn.Session, kind DescriptionType, index int) (string, error) {
+	if index < 1 || index > kind.Max() {
+		return "", fmt.Errorf("invalid %v number: %v", kind, index)
+	}
+	var reply TextDescriptionReply
+	if err := call(ctx, sess, &TextDescriptionRequest{Kind: kind, Index: index}, &reply); err != nil {
+		return "", err
+	}
+	// The M1 replies with the next entity that has a name, or with
+	// an index of zero, if the requested one has none.
+	if reply.Kind != kind || reply.Index != index {
+		return "", nil
+	}
+	return reply.Name, nil
+}
+
+// Description is the name of a single entity.
+type Description struct {
+	Kind  DescriptionType
+	Index int
+	Name  string
+}
+
+// WalkNames calls fn, in index order, for each of the entities of the
+// specified type that have a name, until fn returns false. It relies on
+// the M1 replying to a request for an entity with no name with the next
+// entity that has a name, or with an index of zero if there are no more,
+// so that only one request is made per named entity. This requires M1
+// Ver. 2.4.6 or later.
+func WalkNames(ctx context.Context, sess *streamconn.Session, kind DescriptionType, fn func(Description) bool) error {
+	if kind.Max() == 0 {
+		return fmt.Errorf("invalid description type: %v", kind)
+	}
+	for index := 1; index <= kind.Max(); {
+		var reply TextDescriptionReply
+		if err := call(ctx, sess, &TextDescriptionRequest{Kind: kind, Index: index}, &reply); err != nil {
+			return err
+		}
+		if reply.Kind != kind || reply.Index < index {
+			return nil // no more names.
+		}
+		if !fn(Description{Kind: kind, Index: reply.Index, Name: reply.Name}) {
+			return nil
+		}
+		index = reply.Index + 1
+	}
+	return nil
+}
+
+// GetNames returns the names of all of the entities of the specified
+// type that have a name.
+func GetNames(ctx context.Context, sess *streamconn.Session, kind DescriptionType) ([]Description, error) {
+	names := []Description{}
+	err := WalkNames(ctx, sess, kind, func(d Description) bool {
+		names = append(names, d)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
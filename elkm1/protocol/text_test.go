@@ -0,0 +1,67 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestDescriptionTypes(t *testing.T) {
+	types := protocol.DescriptionTypes()
+	if got, want := len(types), 20; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, dt := range types {
+		parsed, err := protocol.ParseDescriptionType(dt.String())
+		if err != nil || parsed != dt {
+			t.Errorf("%v: got %v, %v", dt, parsed, err)
+		}
+		if dt.Max() == 0 {
+			t.Errorf("%v: missing max", dt)
+		}
+	}
+	if got, want := protocol.ThermostatDescription.String(), "thermostat"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := protocol.FunctionKey6Description.String(), "function-key-6"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := protocol.ParseDescriptionType("nope"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestGetNames(t *testing.T) {
+	ctx := context.Background()
+	ft, sess := newSession(
+		"1BSD06001Home            0096\r\n",
+		"1BSD06003Office          0011\r\n",
+		"1BSD06000                00A0\r\n",
+	)
+	defer sess.Release()
+	names, err := protocol.GetNames(ctx, sess, protocol.TelephoneDescription)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := names, []protocol.Description{
+		{Kind: protocol.TelephoneDescription, Index: 1, Name: "Home            "},
+		{Kind: protocol.TelephoneDescription, Index: 3, Name: "Office          "},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Only one request is made per named entity, plus one to find the end.
+	if got, want := strings.Join(ft.sent, ""), "0Bsd060010060\r\n0Bsd06002005F\r\n0Bsd06004005D\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := protocol.GetName(ctx, sess, protocol.TelephoneDescription, 9); err == nil || !strings.Contains(err.Error(), "telephone") {
+		t.Errorf("expected an error for an invalid telephone: %v", err)
+	}
+}
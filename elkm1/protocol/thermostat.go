@@ -0,0 +1,197 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+const NumThermostats = 16
+
+type ThermostatMode byte
+
+const (
+	ThermostatOff ThermostatMode = iota
+	ThermostatHeat
+	ThermostatCool
+	ThermostatAuto
+	ThermostatEmergencyHeat
+)
+
+var (
+	thermostatModeNames = []string{
+		"off",
+		"heat",
+		"cool",
+		"auto",
+		"emergency-heat",
+	}
+)
+
+func (m ThermostatMode) String() string {
+	if int(m) >= len(thermostatModeNames) {
+		return fmt.Sprintf("UnknownThermostatMode(%v)", int(m))
+	}
+	return thermostatModeNames[m]
+}
+
+// ParseThermostatMode parses the names returned by ThermostatMode.String.
+func ParseThermostatMode(mode string) (ThermostatMode, error) {
+	for i, n := range thermostatModeNames {
+		if n == mode {
+			return ThermostatMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown thermostat mode: %q", mode)
+}
+
+// ThermostatElement identifies the setting changed by a ThermostatSetRequest.
+type ThermostatElement byte
+
+const (
+	ThermostatModeElement ThermostatElement = iota
+	ThermostatHoldElement
+	ThermostatFanElement
+	ThermostatTemperatureElement // M1 Ver. 5.1.6 and later.
+	ThermostatCoolSetPointElement
+	ThermostatHeatSetPointElement
+)
+
+// ThermostatDataRequest (tr) requests the data for a thermostat,
+// M1 Ver. 4.2.6 and later.
+type ThermostatDataRequest struct {
+	Thermostat int
+}
+
+func (m *ThermostatDataRequest) Type() string   { return "tr" }
+func (m *ThermostatDataRequest) Encode() []byte { return appendDecInt(nil, m.Thermostat, 2) }
+
+func (m *ThermostatDataRequest) Decode(data []byte) error {
+	if err := checkLen("tr", data, 2); err != nil {
+		return err
+	}
+	var err error
+	m.Thermostat, _, err = readDecIntN(data, 2)
+	return err
+}
+
+// ThermostatData (TR) is the reply to both a ThermostatDataRequest and a
+// ThermostatSetRequest. Temperatures are in degrees Fahrenheit, a
+// Temperature or Humidity of zero indicates that the value is not available.
+type ThermostatData struct {
+	Thermostat   int
+	Mode         ThermostatMode
+	Hold         bool
+	FanOn        bool
+	Temperature  int
+	HeatSetPoint int
+	CoolSetPoint int
+	Humidity     int
+}
+
+func (m *ThermostatData) Type() string { return "TR" }
+
+func (m *ThermostatData) Encode() []byte {
+	buf := appendDecInt(nil, m.Thermostat, 2)
+	buf = append(buf, '0'+byte(m.Mode), boolDigit(m.Hold), boolDigit(m.FanOn))
+	buf = appendDecInt(buf, m.Temperature, 2)
+	buf = appendDecInt(buf, m.HeatSetPoint, 2)
+	buf = appendDecInt(buf, m.CoolSetPoint, 2)
+	return appendDecInt(buf, m.Humidity, 2)
+}
+
+func (m *ThermostatData) Decode(data []byte) error {
+	if err := checkLen("thermostat data", data, 2+3+2*4); err != nil {
+		return err
+	}
+	var err error
+	if m.Thermostat, data, err = readDecIntN(data, 2); err != nil {
+		return err
+	}
+	m.Mode = ThermostatMode(data[0] - '0')
+	m.Hold = data[1] == '1'
+	m.FanOn = data[2] == '1'
+	data = data[3:]
+	for _, v := range []*int{&m.Temperature, &m.HeatSetPoint, &m.CoolSetPoint, &m.Humidity} {
+		if *v, data, err = readDecIntN(data, 2); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ThermostatSetRequest (ts) sets a single element of a thermostat's data.
+type ThermostatSetRequest struct {
+	Thermostat int
+	Value      int
+	Element    ThermostatElement
+}
+
+func (m *ThermostatSetRequest) Type() string { return "ts" }
+
+func (m *ThermostatSetRequest) Encode() []byte {
+	buf := appendDecInt(appendDecInt(nil, m.Thermostat, 2), m.Value, 2)
+	return appendDecInt(buf, int(m.Element), 1)
+}
+
+func (m *ThermostatSetRequest) Decode(data []byte) error {
+	if err := checkLen("ts", data, 2+2+1); err != nil {
+		return err
+	}
+	var err error
+	if m.Thermostat, data, err = readDecIntN(data, 2); err != nil {
+		return err
+	}
+	if m.Value, data, err = readDecIntN(data, 2); err != nil {
+		return err
+	}
+	element, _, err := readDecIntN(data, 1)
+	m.Element = ThermostatElement(element)
+	return err
+}
+
+func validateThermostat(thermostat int) error {
+	if thermostat < 1 || thermostat > NumThermostats {
+		return fmt.Errorf("invalid thermostat number: %v", thermostat)
+	}
+	return nil
+}
+
+func thermostatCall(ctx context.Context, sess *streamconn.Session, thermostat int, req Message) (ThermostatData, error) {
+	var reply ThermostatData
+	if err := call(ctx, sess, req, &reply); err != nil {
+		return ThermostatData{}, err
+	}
+	if reply.Thermostat != thermostat {
+		return ThermostatData{}, fmt.Errorf("unexpected or invalid thermostat: got %v, expected %v", reply.Thermostat, thermostat)
+	}
+	return reply, nil
+}
+
+// GetThermostat returns the current data for the specified thermostat.
+func GetThermostat(ctx context.Context, sess *streamconn.Session, thermostat int) (ThermostatData, error) {
+	if err := validateThermostat(thermostat); err != nil {
+		return ThermostatData{}, err
+	}
+	return thermostatCall(ctx, sess, thermostat, &ThermostatDataRequest{Thermostat: thermostat})
+}
+
+// SetThermostat sets the specified element of a thermostat's data and
+// returns the thermostat's updated data.
+func SetThermostat(ctx context.Context, sess *streamconn.Session, thermostat int, element ThermostatElement, value int) (ThermostatData, error) {
+	if err := validateThermostat(thermostat); err != nil {
+		return ThermostatData{}, err
+	}
+	if element > ThermostatHeatSetPointElement {
+		return ThermostatData{}, fmt.Errorf("invalid thermostat element: %v", element)
+	}
+	if value < 0 || value > 99 {
+		return ThermostatData{}, fmt.Errorf("invalid thermostat value: %v, must be between 0 and 99", value)
+	}
+	return thermostatCall(ctx, sess, thermostat, &ThermostatSetRequest{Thermostat: thermostat, Value: value, Element: element})
+}
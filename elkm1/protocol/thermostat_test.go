@@ -0,0 +1,61 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestThermostat(t *testing.T) {
+	cool := protocol.ThermostatData{
+		Thermostat:   1,
+		Mode:         protocol.ThermostatCool,
+		Temperature:  72,
+		HeatSetPoint: 68,
+		CoolSetPoint: 75,
+	}
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"08tr0100F1\r\n", &protocol.ThermostatDataRequest{Thermostat: 1}},
+		{"13TR01200726875000000\r\n", &cool},
+		{"0Bts01704004B\r\n", &protocol.ThermostatSetRequest{Thermostat: 1, Value: 70, Element: protocol.ThermostatCoolSetPointElement}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	ctx := context.Background()
+	ft, sess := newSession("13TR01200726875000000\r\n")
+	defer sess.Release()
+	td, err := protocol.SetThermostat(ctx, sess, 1, protocol.ThermostatCoolSetPointElement, 70)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := td, cool; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if got, want := strings.Join(ft.sent, ""), "0Bts01704004B\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := protocol.GetThermostat(ctx, sess, 17); err == nil {
+		t.Errorf("expected an error for an invalid thermostat")
+	}
+	if _, err := protocol.SetThermostat(ctx, sess, 1, protocol.ThermostatHeatSetPointElement, 100); err == nil {
+		t.Errorf("expected an error for an invalid value")
+	}
+
+	for i, n := range []string{"off", "heat", "cool", "auto", "emergency-heat"} {
+		m, err := protocol.ParseThermostatMode(n)
+		if err != nil || int(m) != i || m.String() != n {
+			t.Errorf("%v: got %v, %v", n, m, err)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+// troubleStatusLen is the number of trouble positions in SS messages.
+const troubleStatusLen = 34
+
+// TroubleStatusRequest (ss) requests the system trouble status.
+type TroubleStatusRequest struct{}
+
+func (m *TroubleStatusRequest) Type() string             { return "ss" }
+func (m *TroubleStatusRequest) Encode() []byte           { return nil }
+func (m *TroubleStatusRequest) Decode(data []byte) error { return checkLen("ss", data, 0) }
+
+// TroubleStatus is the reply to a TroubleStatusRequest, it is also sent
+// by the M1 whenever the trouble status changes. Troubles that are
+// associated with a zone record the zone number, zero if there is no
+// such trouble.
+type TroubleStatus struct {
+	ACFail                    bool
+	BoxTamperZone             int
+	FailToCommunicate         bool
+	EEPROMMemoryError         bool
+	LowBatteryControl         bool
+	TransmitterLowBatteryZone int
+	OverCurrent               bool
+	TelephoneFault            bool
+	Output2                   bool
+	MissingKeypad             bool
+	ZoneExpander              bool
+	OutputExpander            bool
+	ELKRPRemoteAccess         bool
+	CommonAreaNotArmed        bool
+	FlashMemoryError          bool
+	SecurityAlertZone         int
+	SerialPortExpander        bool
+	LostTransmitterZone       int
+	GESmokeCleanMe            bool
+	Ethernet                  bool
+	DisplayMessageLine1       bool
+	DisplayMessageLine2       bool
+	FireZone                  int
+}
+
+// troubleField describes a single trouble position, exactly one of flag
+// or zone is set.
+type troubleField struct {
+	pos  int
+	name string
+	flag *bool
+	zone *int
+}
+
+func (m *TroubleStatus) fields() []troubleField {
+	return []troubleField{
+		{pos: 0, name: "ac-fail", flag: &m.ACFail},
+		{pos: 1, name: "box-tamper", zone: &m.BoxTamperZone},
+		{pos: 2, name: "fail-to-communicate", flag: &m.FailToCommunicate},
+		{pos: 3, name: "eeprom-memory-error", flag: &m.EEPROMMemoryError},
+		{pos: 4, name: "low-battery", flag: &m.LowBatteryControl},
+		{pos: 5, name: "transmitter-low-battery", zone: &m.TransmitterLowBatteryZone},
+		{pos: 6, name: "over-current", flag: &m.OverCurrent},
+		{pos: 7, name: "telephone-fault", flag: &m.TelephoneFault},
+		{pos: 9, name: "output-2", flag: &m.Output2},
+		{pos: 10, name: "missing-keypad", flag: &m.MissingKeypad},
+		{pos: 11, name: "zone-expander", flag: &m.ZoneExpander},
+		{pos: 12, name: "output-expander", flag: &m.OutputExpander},
+		{pos: 14, name: "elkrp-remote-access", flag: &m.ELKRPRemoteAccess},
+		{pos: 16, name: "common-area-not-armed", flag: &m.CommonAreaNotArmed},
+		{pos: 17, name: "flash-memory-error", flag: &m.FlashMemoryError},
+		{pos: 18, name: "security-alert", zone: &m.SecurityAlertZone},
+		{pos: 19, name: "serial-port-expander", flag: &m.SerialPortExpander},
+		{pos: 20, name: "lost-transmitter", zone: &m.LostTransmitterZone},
+		{pos: 21, name: "ge-smoke-clean-me", flag: &m.GESmokeCleanMe},
+		{pos: 22, name: "ethernet", flag: &m.Ethernet},
+		{pos: 31, name: "display-message-line-1", flag: &m.DisplayMessageLine1},
+		{pos: 32, name: "display-message-line-2", flag: &m.DisplayMessageLine2},
+		{pos: 33, name: "fire", zone: &m.FireZone},
+	}
+}
+
+func (m *TroubleStatus) Type() string { return "SS" }
+
+func (m *TroubleStatus) Encode() []byte {
+	buf := make([]byte, troubleStatusLen)
+	for i := range buf {
+		buf[i] = '0'
+	}
+	for _, f := range m.fields() {
+		if f.flag != nil {
+			buf[f.pos] = boolDigit(*f.flag)
+			continue
+		}
+		buf[f.pos] = '0' + byte(*f.zone)
+	}
+	return buf
+}
+
+// Decode decodes the trouble status, each position is encoded as '0'
+// plus the zone number, or plus one for troubles with no zone number.
+func (m *TroubleStatus) Decode(data []byte) error {
+	if err := checkLen("trouble status", data, troubleStatusLen); err != nil {
+		return err
+	}
+	*m = TroubleStatus{}
+	for _, f := range m.fields() {
+		v := int(data[f.pos]) - '0'
+		if v < 0 {
+			return fmt.Errorf("invalid trouble status for %v: %q", f.name, data[f.pos])
+		}
+		if f.flag != nil {
+			*f.flag = v != 0
+			continue
+		}
+		*f.zone = v
+	}
+	return nil
+}
+
+// Trouble represents a single active trouble.
+type Trouble struct {
+	Name string
+	// Zone is the zone associated with the trouble, if any.
+	Zone int
+}
+
+func (t Trouble) String() string {
+	if t.Zone != 0 {
+		return fmt.Sprintf("%v (zone %v)", t.Name, t.Zone)
+	}
+	return t.Name
+}
+
+// Troubles returns the currently active troubles.
+func (m *TroubleStatus) Troubles() []Trouble {
+	var troubles []Trouble
+	for _, f := range m.fields() {
+		switch {
+		case f.flag != nil && *f.flag:
+			troubles = append(troubles, Trouble{Name: f.name})
+		case f.zone != nil && *f.zone != 0:
+			troubles = append(troubles, Trouble{Name: f.name, Zone: *f.zone})
+		}
+	}
+	return troubles
+}
+
+// TroubleNames returns the names of all of the troubles reported by
+// TroubleStatus.Troubles.
+func TroubleNames() []string {
+	var m TroubleStatus
+	fields := m.fields()
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	return names
+}
+
+// GetTroubleStatus returns the system trouble status.
+func GetTroubleStatus(ctx context.Context, sess *streamconn.Session) (TroubleStatus, error) {
+	var reply TroubleStatus
+	err := call(ctx, sess, &TroubleStatusRequest{}, &reply)
+	return reply, err
+}
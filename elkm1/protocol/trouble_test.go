@@ -0,0 +1,53 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestTroubleStatus(t *testing.T) {
+	for _, tc := range []struct {
+		msg      string
+		decoded  protocol.Message
+		troubles string
+	}{
+		{"06ss0054\r\n", &protocol.TroubleStatusRequest{}, ""},
+		{"28SS1000000000000000000000000000000000002F\r\n", &protocol.TroubleStatus{ACFail: true}, "[ac-fail]"},
+		{"28SS000000000100000000000000000000010A001D\r\n", &protocol.TroubleStatus{
+			Output2: true, DisplayMessageLine1: true, FireZone: 17}, "[output-2 display-message-line-1 fire (zone 17)]"},
+		{"28SS0000110000000000000000000000000000002E\r\n", &protocol.TroubleStatus{
+			LowBatteryControl: true, TransmitterLowBatteryZone: 1}, "[low-battery transmitter-low-battery (zone 1)]"},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+		if ts, ok := tc.decoded.(*protocol.TroubleStatus); ok {
+			if got, want := fmt.Sprintf("%v", ts.Troubles()), tc.troubles; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		}
+	}
+
+	if got, want := len(protocol.TroubleNames()), 23; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	ctx := context.Background()
+	ft, sess := newSession("28SS1000000000000000000000000000000000002F\r\n")
+	ts, err := protocol.GetTroubleStatus(ctx, sess)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ts.ACFail || len(ts.Troubles()) != 1 {
+		t.Errorf("unexpected trouble status: %#v", ts)
+	}
+	if got, want := strings.Join(ft.sent, ""), "06ss0054\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+const (
+	NumCounters     = 64
+	NumCustomValues = 20
+	// MaxValue is the maximum value of a counter or custom value.
+	MaxValue = 65535
+)
+
+func validateValue(value int) error {
+	if value < 0 || value > MaxValue {
+		return fmt.Errorf("invalid value: %v, must be between 0 and %v", value, MaxValue)
+	}
+	return nil
+}
+
+func validateCounter(counter int) error {
+	if counter < 1 || counter > NumCounters {
+		return fmt.Errorf("invalid counter number: %v", counter)
+	}
+	return nil
+}
+
+// CounterRequest (cv) requests the value of a counter, M1 Ver. 4.1.11,
+// 5.1.6 and later.
+type CounterRequest struct {
+	Counter int
+}
+
+func (m *CounterRequest) Type() string   { return "cv" }
+func (m *CounterRequest) Encode() []byte { return appendDecInt(nil, m.Counter, 2) }
+
+func (m *CounterRequest) Decode(data []byte) error {
+	if err := checkLen("cv", data, 2); err != nil {
+		return err
+	}
+	var err error
+	m.Counter, _, err = readDecIntN(data, 2)
+	return err
+}
+
+// CounterWriteRequest (cx) sets the value of a counter.
+type CounterWriteRequest struct {
+	Counter int
+	Value   int
+}
+
+func (m *CounterWriteRequest) Type() string { return "cx" }
+
+func (m *CounterWriteRequest) Encode() []byte {
+	return appendDecInt(appendDecInt(nil, m.Counter, 2), m.Value, 5)
+}
+
+func (m *CounterWriteRequest) Decode(data []byte) error {
+	if err := checkLen("cx", data, 2+5); err != nil {
+		return err
+	}
+	var err error
+	if m.Counter, data, err = readDecIntN(data, 2); err != nil {
+		return err
+	}
+	m.Value, _, err = readDecIntN(data, 5)
+	return err
+}
+
+// CounterReply (CV) is the reply to both a CounterRequest and a
+// CounterWriteRequest.
+type CounterReply struct {
+	Counter int
+	Value   int
+}
+
+func (m *CounterReply) Type() string { return "CV" }
+
+func (m *CounterReply) Encode() []byte {
+	return appendDecInt(appendDecInt(nil, m.Counter, 2), m.Value, 5)
+}
+
+func (m *CounterReply) Decode(data []byte) error {
+	if err := checkLen("counter", data, 2+5); err != nil {
+		return err
+	}
+	var err error
+	if m.Counter, data, err = readDecIntN(data, 2); err != nil {
+		return err
+	}
+	m.Value, _, err = readDecIntN(data, 5)
+	return err
+}
+
+func counterCall(ctx context.Context, sess *streamconn.Session, counter int, req Message) (int, error) {
+	var reply CounterReply
+	if err := call(ctx, sess, req, &reply); err != nil {
+		return 0, err
+	}
+	if reply.Counter != counter {
+		return 0, fmt.Errorf("unexpected counter: got %v, expected %v", reply.Counter, counter)
+	}
+	return reply.Value, nil
+}
+
+// GetCounter returns the value of the specified counter.
+func GetCounter(ctx context.Context, sess *streamconn.Session, counter int) (int, error) {
+	if err := validateCounter(counter); err != nil {
+		return 0, err
+	}
+	return counterCall(ctx, sess, counter, &CounterRequest{Counter: counter})
+}
+
+// SetCounter sets the value of the specified counter and returns the
+// value reported by the M1.
+func SetCounter(ctx context.Context, sess *streamconn.Session, counter, value int) (int, error) {
+	if err := validateCounter(counter); err != nil {
+		return 0, err
+	}
+	if err := validateValue(value); err != nil {
+		return 0, err
+	}
+	return counterCall(ctx, sess, counter, &CounterWriteRequest{Counter: counter, Value: value})
+}
+
+// CustomValueFormat is the format of a custom value as configured in
+// the M1.
+type CustomValueFormat byte
+
+const (
+	CustomNumber CustomValueFormat = iota
+	CustomTimer
+	CustomTimeOfDay
+)
+
+var (
+	customValueFormatNames = []string{
+		"number",
+		"timer",
+		"time-of-day",
+	}
+)
+
+func (f CustomValueFormat) String() string {
+	if int(f) >= len(customValueFormatNames) {
+		return fmt.Sprintf("UnknownCustomValueFormat(%v)", int(f))
+	}
+	return customValueFormatNames[f]
+}
+
+// CustomValue represents a custom value and its format. Time of day
+// values are stored with the hours in the high byte and the minutes in
+// the low byte.
+type CustomValue struct {
+	Value  int
+	Format CustomValueFormat
+}
+
+// TimeOfDay returns the hours and minutes of a time of day value.
+func (v CustomValue) TimeOfDay() (hours, minutes int) {
+	return v.Value >> 8, v.Value & 0xff
+}
+
+// TimeOfDayValue returns the value used to store the specified time of day.
+func TimeOfDayValue(hours, minutes int) int {
+	return hours<<8 | minutes
+}
+
+func (v CustomValue) String() string {
+	if v.Format == CustomTimeOfDay {
+		h, m := v.TimeOfDay()
+		return fmt.Sprintf("%02d:%02d", h, m)
+	}
+	return strconv.Itoa(v.Value)
+}
+
+// ParseCustomValue parses a value in the specified format, time of day
+// values are specified as HH:MM using a 24 hour clock and all others
+// as decimal numbers.
+func ParseCustomValue(format CustomValueFormat, value string) (int, error) {
+	if format == CustomTimeOfDay {
+		hs, ms, ok := strings.Cut(value, ":")
+		h, herr := strconv.Atoi(hs)
+		m, merr := strconv.Atoi(ms)
+		if !ok || herr != nil || merr != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+			return 0, fmt.Errorf("invalid time of day: %q, must be HH:MM", value)
+		}
+		return TimeOfDayValue(h, m), nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %v: %w", value, err)
+	}
+	if err := validateValue(v); err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+func validateCustomValue(index int) error {
+	if index < 1 || index > NumCustomValues {
+		return fmt.Errorf("invalid custom value number: %v", index)
+	}
+	return nil
+}
+
+// CustomValueRequest (cr) requests a single custom value.
+type CustomValueRequest struct {
+	Index int
+}
+
+func (m *CustomValueRequest) Type() string   { return "cr" }
+func (m *CustomValueRequest) Encode() []byte { return appendDecInt(nil, m.Index, 2) }
+
+func (m *CustomValueRequest) Decode(data []byte) error {
+	if err := checkLen("cr", data, 2); err != nil {
+		return err
+	}
+	var err error
+	m.Index, _, err = readDecIntN(data, 2)
+	return err
+}
+
+// CustomValuesRequest (cp) requests all of the custom values.
+type CustomValuesRequest struct{}
+
+func (m *CustomValuesRequest) Type() string   { return "cp" }
+func (m *CustomValuesRequest) Encode() []byte { return nil }
+
+func (m *CustomValuesRequest) Decode(data []byte) error {
+	return checkLen("cp", data, 0)
+}
+
+// CustomValueReply (CR) is the reply to both a CustomValueRequest and a
+// CustomValuesRequest. Index is zero and Values contains all of the
+// custom values in reply to a CustomValuesRequest, otherwise Values
+// contains the single requested value.
+type CustomValueReply struct {
+	Index  int
+	Values []CustomValue
+}
+
+func (m *CustomValueReply) Type() string { return "CR" }
+
+func (m *CustomValueReply) Encode() []byte {
+	buf := appendDecInt(nil, m.Index, 2)
+	for _, v := range m.Values {
+		buf = appendDecInt(buf, v.Value, 5)
+		buf = append(buf, '0'+byte(v.Format))
+	}
+	return buf
+}
+
+func (m *CustomValueReply) Decode(data []byte) error {
+	n := 1
+	if len(data) >= 2 && data[0] == '0' && data[1] == '0' {
+		n = NumCustomValues
+	}
+	if err := checkLen("custom value", data, 2+n*6); err != nil {
+		return err
+	}
+	var err error
+	if m.Index, data, err = readDecIntN(data, 2); err != nil {
+		return err
+	}
+	m.Values = make([]CustomValue, n)
+	for i := range m.Values {
+		if m.Values[i].Value, data, err = readDecIntN(data, 5); err != nil {
+			return err
+		}
+		m.Values[i].Format = CustomValueFormat(data[0] - '0')
+		data = data[1:]
+	}
+	return nil
+}
+
+// CustomValueWriteRequest (cw) sets a custom value, the M1 does not
+// reply to this request.
+type CustomValueWriteRequest struct {
+	Index int
+	Value int
+}
+
+func (m *CustomValueWriteRequest) Type() string { return "cw" }
+
+func (m *CustomValueWriteRequest) Encode() []byte {
+	return appendDecInt(appendDecInt(nil, m.Index, 2), m.Value, 5)
+}
+
+func (m *CustomValueWriteRequest) Decode(data []byte) error {
+	if err := checkLen("cw", data, 2+5); err != nil {
+		return err
+	}
+	var err error
+	if m.Index, data, err = readDecIntN(data, 2); err != nil {
+		return err
+	}
+	m.Value, _, err = readDecIntN(data, 5)
+	return err
+}
+
+// GetCustomValue returns the specified custom value.
+func GetCustomValue(ctx context.Context, sess *streamconn.Session, index int) (CustomValue, error) {
+	if err := validateCustomValue(index); err != nil {
+		return CustomValue{}, err
+	}
+	var reply CustomValueReply
+	if err := call(ctx, sess, &CustomValueRequest{Index: index}, &reply); err != nil {
+		return CustomValue{}, err
+	}
+	if reply.Index != index {
+		return CustomValue{}, fmt.Errorf("unexpected custom value: got %v, expected %v", reply.Index, index)
+	}
+	return reply.Values[0], nil
+}
+
+// GetCustomValues returns all of the custom values.
+func GetCustomValues(ctx context.Context, sess *streamconn.Session) ([]CustomValue, error) {
+	var reply CustomValueReply
+	if err := call(ctx, sess, &CustomValuesRequest{}, &reply); err != nil {
+		return nil, err
+	}
+	if reply.Index != 0 {
+		return nil, fmt.Errorf("unexpected custom value: got %v, expected all values", reply.Index)
+	}
+	return reply.Values, nil
+}
+
+// SetCustomValue sets the specified custom value, use TimeOfDayValue
+// or ParseCustomValue to obtain the value for time of day values.
+func SetCustomValue(ctx context.Context, sess *streamconn.Session, index, value int) error {
+	if err := validateCustomValue(index); err != nil {
+		return err
+	}
+	if err := validateValue(value); err != nil {
+		return err
+	}
+	return send(ctx, sess, &CustomValueWriteRequest{Index: index, Value: value})
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestValues(t *testing.T) {
+	all := &protocol.CustomValueReply{}
+	for i := 0; i < protocol.NumCustomValues; i++ {
+		all.Values = append(all.Values, protocol.CustomValue{Value: i * 10, Format: protocol.CustomValueFormat(i % 3)})
+	}
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"08cv0100FE\r\n", &protocol.CounterRequest{Counter: 1}},
+		{"0Dcx011234500F1\r\n", &protocol.CounterWriteRequest{Counter: 1, Value: 12345}},
+		{"0DCV0100123003C\r\n", &protocol.CounterReply{Counter: 1, Value: 123}},
+		{"08cr010002\r\n", &protocol.CustomValueRequest{Index: 1}},
+		{"06cp0067\r\n", &protocol.CustomValuesRequest{}},
+		{"0ECR01001230000F\r\n", &protocol.CustomValueReply{Index: 1, Values: []protocol.CustomValue{{Value: 123}}}},
+		{"0ECR010541620003\r\n", &protocol.CustomValueReply{Index: 1, Values: []protocol.CustomValue{{Value: 5416, Format: protocol.CustomTimeOfDay}}}},
+		{"80CR00000000000101000202000300000401000502000600000701000802000900001001001102001200001301001402001500001601001702001800001901004C\r\n", all},
+		{"0Dcw050012300F7\r\n", &protocol.CustomValueWriteRequest{Index: 5, Value: 123}},
+		{"0Dcw010541600F1\r\n", &protocol.CustomValueWriteRequest{Index: 1, Value: 5416}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	tod := protocol.CustomValue{Value: 5416, Format: protocol.CustomTimeOfDay}
+	if got, want := tod.String(), "21:40"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	v, err := protocol.ParseCustomValue(protocol.CustomTimeOfDay, "21:40")
+	if err != nil || v != 5416 {
+		t.Errorf("got %v, %v, want 5416", v, err)
+	}
+	for _, bad := range []string{"24:00", "12", "12:60", "a:b"} {
+		if _, err := protocol.ParseCustomValue(protocol.CustomTimeOfDay, bad); err == nil {
+			t.Errorf("%v: expected an error", bad)
+		}
+	}
+
+	ctx := context.Background()
+	ft, sess := newSession("0DCV01123450033\r\n", "0ECR010541620003\r\n")
+	defer sess.Release()
+	counter, err := protocol.SetCounter(ctx, sess, 1, 12345)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := counter, 12345; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	cv, err := protocol.GetCustomValue(ctx, sess, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := cv, tod; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := strings.Join(ft.sent, ""), "0Dcx011234500F1\r\n08cr010002\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := protocol.SetCounter(ctx, sess, 65, 0); err == nil {
+		t.Errorf("expected an error for an invalid counter")
+	}
+	if err := protocol.SetCustomValue(ctx, sess, 1, protocol.MaxValue+1); err == nil {
+		t.Errorf("expected an error for an invalid value")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+// Version represents an M1 or M1XEP firmware version.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// IsZero returns true if the version is unknown, eg. the M1XEP version
+// of a panel without an M1XEP.
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+// Compare returns -1, 0 or +1 depending on whether v is less than, equal
+// to or greater than o.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+	return 0
+}
+
+// ParseVersion parses a version of the form major.minor.patch.
+func ParseVersion(v string) (Version, error) {
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid version: %q", v)
+	}
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version: %q", v)
+		}
+		nums[i] = n
+	}
+	return Version{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+func appendHexVersion(buf []byte, v Version) []byte {
+	for _, n := range []int{v.Major, v.Minor, v.Patch} {
+		buf = append(buf, hexLookup[(n>>4)&0x0f], hexLookup[n&0x0f])
+	}
+	return buf
+}
+
+func readHexVersion(data []byte) (Version, []byte) {
+	var v Version
+	v.Major, data = readHexInt(data)
+	v.Minor, data = readHexInt(data)
+	v.Patch, data = readHexInt(data)
+	return v, data
+}
+
+// VersionRequest (vn) requests the M1 and M1XEP firmware versions, M1
+// Ver. 4.1.12, 5.1.12 and later.
+type VersionRequest struct{}
+
+func (m *VersionRequest) Type() string   { return "vn" }
+func (m *VersionRequest) Encode() []byte { return nil }
+
+func (m *VersionRequest) Decode(data []byte) error {
+	return checkLen("vn", data, 0)
+}
+
+// versionReserved is the number of bytes reserved for future use in
+// a VN reply.
+const versionReserved = 36
+
+// VersionReply (VN) is the reply to a VersionRequest.
+type VersionReply struct {
+	M1    Version
+	M1XEP Version
+}
+
+func (m *VersionReply) Type() string { return "VN" }
+
+func (m *VersionReply) Encode() []byte {
+	buf := appendHexVersion(appendHexVersion(nil, m.M1), m.M1XEP)
+	return append(buf, strings.Repeat("0", versionReserved)...)
+}
+
+func (m *VersionReply) Decode(data []byte) error {
+	if err := checkLen("version", data, 6+6+versionReserved); err != nil {
+		return err
+	}
+	m.M1, data = readHexVersion(data)
+	m.M1XEP, _ = readHexVersion(data)
+	return nil
+}
+
+// GetVersion returns the M1 and M1XEP firmware versions.
+func GetVersion(ctx context.Context, sess *streamconn.Session) (VersionReply, error) {
+	var reply VersionReply
+	err := call(ctx, sess, &VersionRequest{}, &reply)
+	return reply, err
+}
+
+// DetectVersion is like GetVersion except that it waits at most timeout
+// for a reply and retries once if none is received, so that a single lost
+// reply is tolerated. Firmware older than 4.1.12 or 5.1.12 never replies
+// to a VersionRequest, in which case the error from the second attempt
+// is returned and the version should be treated as unknown.
+func DetectVersion(ctx context.Context, sess *streamconn.Session, timeout time.Duration) (VersionReply, error) {
+	var reply VersionReply
+	var err error
+	for range 2 {
+		tctx, cancel := context.WithTimeout(ctx, timeout)
+		reply, err = GetVersion(tctx, sess)
+		cancel()
+		if err == nil || !isTimeout(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	return reply, err
+}
+
+// ErrUnsupported is returned for requests that are not supported by the
+// M1's firmware version.
+var ErrUnsupported = errors.New("not supported by this M1 firmware version")
+
+// minVersions records the minimum M1 firmware version required for
+// requests, keyed by request type. Features are generally available in
+// both the 4.x and 5.x firmware series, but were introduced at different
+// times in each.
+var minVersions = map[string][]Version{
+	"a7": {{4, 2, 8}},
+	"a8": {{4, 2, 8}},
+	"a9": {{5, 3, 0}},
+	"a:": {{5, 3, 0}},
+	"az": {{4, 3, 9}},
+	"cv": {{4, 1, 11}, {5, 1, 6}},
+	"cx": {{4, 1, 11}, {5, 1, 6}},
+	"ka": {{4, 2, 5}},
+	"kf": {{4, 2, 5}},
+	"ld": {{4, 3, 2}},
+	"le": {{4, 1, 2}, {5, 1, 2}},
+	"lw": {{4, 3, 4}},
+	"rw": {{4, 3, 2}},
+	"ss": {{4, 5, 4}, {5, 1, 4}},
+	"st": {{4, 2, 8}},
+	"tr": {{4, 2, 6}},
+	"ts": {{4, 2, 6}},
+	"vn": {{4, 1, 12}, {5, 1, 12}},
+	"zt": {{4, 5, 23}, {5, 1, 23}},
+	"zv": {{4, 2, 8}},
+}
+
+// MinimumVersion returns the minimum M1 firmware version, in the same
+// series as v, that supports the specified request type.
+func MinimumVersion(typ string, v Version) (Version, bool) {
+	mins, ok := minVersions[typ]
+	if !ok {
+		return Version{}, false
+	}
+	for _, minimum := range mins {
+		if minimum.Major == v.Major {
+			return minimum, true
+		}
+	}
+	// Use the earliest series that supports the request if there is no
+	// entry for v's series.
+	return mins[0], true
+}
+
+// Supports returns nil if the M1 firmware version v supports the
+// specified request type, or an error that wraps ErrUnsupported if not.
+// Unknown, ie. zero, versions are assumed to support all requests.
+func Supports(v Version, typ string) error {
+	if v.IsZero() {
+		return nil
+	}
+	minimum, ok := MinimumVersion(typ, v)
+	if !ok {
+		return nil
+	}
+	if minimum.Major < v.Major || v.Compare(minimum) >= 0 {
+		return nil
+	}
+	return fmt.Errorf("%v requires M1 version %v or later, this M1 is version %v: %w", typ, minimum, v, ErrUnsupported)
+}
+
+type versionKey struct{}
+
+// ContextWithVersion returns a context that carries the M1 firmware
+// version. All of the request functions in this package refuse to send
+// requests that the version in their context does not support rather
+// than waiting for a reply that will never be sent.
+func ContextWithVersion(ctx context.Context, v Version) context.Context {
+	return context.WithValue(ctx, versionKey{}, v)
+}
+
+// VersionFromContext returns the M1 firmware version stored in ctx, or
+// the zero version.
+func VersionFromContext(ctx context.Context) Version {
+	v, _ := ctx.Value(versionKey{}).(Version)
+	return v
+}
+
+func checkSupported(ctx context.Context, req Message) error {
+	return Supports(VersionFromContext(ctx), req.Type())
+}
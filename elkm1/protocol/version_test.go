@@ -0,0 +1,125 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestVersion(t *testing.T) {
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"06vn0056\r\n", &protocol.VersionRequest{}},
+		{"36VN05010C0103020000000000000000000000000000000000000074\r\n", &protocol.VersionReply{
+			M1:    protocol.Version{Major: 5, Minor: 1, Patch: 12},
+			M1XEP: protocol.Version{Major: 1, Minor: 3, Patch: 2},
+		}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	for _, tc := range []struct {
+		version   string
+		typ       string
+		supported bool
+	}{
+		{"0.0.0", "lw", true},
+		{"4.3.4", "lw", true},
+		{"4.3.3", "lw", false},
+		{"5.0.0", "lw", true},
+		{"4.1.11", "cv", true},
+		{"5.1.5", "cv", false},
+		{"5.1.6", "cv", true},
+		{"4.5.22", "zt", false},
+		{"5.1.23", "zt", true},
+		{"4.5.30", "a9", false},
+		{"5.3.0", "a9", true},
+		{"2.0.0", "rw", false},
+		{"2.0.0", "rr", true},
+		{"2.0.0", "zd", true},
+		{"2.0.0", "zs", true},
+	} {
+		v, err := protocol.ParseVersion(tc.version)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tc.version, err)
+		}
+		err = protocol.Supports(v, tc.typ)
+		if got, want := err == nil, tc.supported; got != want {
+			t.Errorf("%v: %v: got %v, want %v", tc.version, tc.typ, got, want)
+		}
+		if err != nil && !errors.Is(err, protocol.ErrUnsupported) {
+			t.Errorf("%v: %v: unexpected error: %v", tc.version, tc.typ, err)
+		}
+	}
+
+	// Unsupported requests are refused without being sent.
+	ctx := protocol.ContextWithVersion(context.Background(), protocol.Version{Major: 4, Minor: 2, Patch: 0})
+	ft, sess := newSession()
+	defer sess.Release()
+	if _, err := protocol.GetTemperatures(ctx, sess); !errors.Is(err, protocol.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported: %v", err)
+	}
+	if err := protocol.TriggerZone(ctx, sess, 1); !errors.Is(err, protocol.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported: %v", err)
+	}
+	if got, want := len(ft.sent), 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDetectVersion(t *testing.T) {
+	ctx := context.Background()
+	pt := newPipeTransport()
+	d := protocol.NewDispatcher()
+	d.Start(ctx, pt, time.Minute)
+	defer close(pt.lines)
+	var mgr streamconn.SessionManager
+	sess := mgr.New(pt, noIdle{})
+	defer sess.Release()
+	ctx = protocol.ContextWithDispatcher(ctx, d)
+
+	// Firmware that predates vn does not reply to it, the request is
+	// retried once and then the version is left unknown.
+	if _, err := protocol.DetectVersion(ctx, sess, 10*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got, want := len(pt.getSent()), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// A single lost reply is tolerated.
+	sent := 0
+	pt.onSend = func(string) {
+		if sent++; sent == 2 {
+			pt.lines <- "36VN05010C0103020000000000000000000000000000000000000074\r\n"
+		}
+	}
+	v, err := protocol.DetectVersion(ctx, sess, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := v.M1, (protocol.Version{Major: 5, Minor: 1, Patch: 12}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	pt.onSend = func(string) {
+		pt.lines <- "36VN05010C0103020000000000000000000000000000000000000074\r\n"
+	}
+	v, err = protocol.DetectVersion(ctx, sess, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := v.M1, (protocol.Version{Major: 5, Minor: 1, Patch: 12}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
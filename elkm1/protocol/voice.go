@@ -0,0 +1,287 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/cosnicolaou/automation/net/streamconn"
+)
+
+const (
+	NumWords   = 473
+	NumPhrases = 320
+)
+
+// SpeakWordRequest (sw) speaks a word at the M1's voice/siren output.
+// The M1 does not reply to sw requests.
+type SpeakWordRequest struct {
+	Word int
+}
+
+func (m *SpeakWordRequest) Type() string   { return "sw" }
+func (m *SpeakWordRequest) Encode() []byte { return appendDecInt(nil, m.Word, 3) }
+
+func (m *SpeakWordRequest) Decode(data []byte) error {
+	if err := checkLen("sw", data, 3); err != nil {
+		return err
+	}
+	var err error
+	m.Word, _, err = readDecIntN(data, 3)
+	return err
+}
+
+// SpeakPhraseRequest (sp) speaks a phrase at the M1's voice/siren output.
+// The M1 does not reply to sp requests.
+type SpeakPhraseRequest struct {
+	Phrase int
+}
+
+func (m *SpeakPhraseRequest) Type() string   { return "sp" }
+func (m *SpeakPhraseRequest) Encode() []byte { return appendDecInt(nil, m.Phrase, 3) }
+
+func (m *SpeakPhraseRequest) Decode(data []byte) error {
+	if err := checkLen("sp", data, 3); err != nil {
+		return err
+	}
+	var err error
+	m.Phrase, _, err = readDecIntN(data, 3)
+	return err
+}
+
+// The names of the words and phrases, indexed by their number minus one,
+// as listed in the Word and Phrase Table of the M1 protocol specification.
+// The specification numbers phrases from 0 (vm0), whereas sp requests
+// number them from 1. Words 11 to 20 are not implemented and have no name.
+// The custom words and phrases may be recorded using ElkRP.
+var (
+	wordNames = []string{
+		/*   1 */ "custom1", "custom2", "custom3", "custom4", "custom5", "custom6", "custom7", "custom8",
+		/*   9 */ "custom9", "custom10", "", "", "", "", "", "",
+		/*  17 */ "", "", "", "", "zero", "one", "two", "three",
+		/*  25 */ "four", "five", "six", "seven", "eight", "nine", "ten", "eleven",
+		/*  33 */ "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen",
+		/*  41 */ "twenty", "thirty", "fourty", "fifty", "sixty", "seventy", "eighty", "ninety",
+		/*  49 */ "hundred", "thousand", "200ms-silence", "500ms-silence", "800hz-tone", "a", "access", "acknowledged",
+		/*  57 */ "ac-power", "activate", "activated", "active", "adjust", "air", "alarm", "alert",
+		/*  65 */ "all", "am", "an", "and", "answer", "any", "are", "area",
+		/*  73 */ "arm", "armed", "at", "attic", "audio", "auto", "authorized", "automatic",
+		/*  81 */ "automation", "auxiliary", "away", "b", "back", "barn", "basement", "bathroom",
+		/*  89 */ "battery", "bedroom", "been", "bell", "bottom", "break", "breakfast", "bright",
+		/*  97 */ "building", "burglar", "button", "by", "bypassed", "cabinet", "call", "camera",
+		/* 105 */ "cancel", "carbon-monoxide", "card", "center", "central", "change", "check", "chime",
+		/* 113 */ "circuit", "clear", "closed", "closet", "code", "cold", "condition", "connect",
+		/* 121 */ "control", "cool", "cooling", "corner", "crawlspace", "danger", "day", "deck",
+		/* 129 */ "decrease", "defective", "degrees", "delay", "den", "denied", "detected", "detector",
+		/* 137 */ "device", "dial", "dialing", "dim", "dining-room", "disable", "disarm", "disarmed",
+		/* 145 */ "dock", "door", "doors", "down", "driveway", "east", "emergency", "enable",
+		/* 153 */ "end", "energy", "enrollment", "enter", "entering", "entertainment", "enter-the", "entry",
+		/* 161 */ "environment", "equipment", "error", "evacuate", "event", "exercise", "expander", "exit",
+		/* 169 */ "exterior", "f", "fail", "failure", "family-room", "fan", "feed", "fence",
+		/* 177 */ "fire", "first", "flood", "floor", "followed", "force", "fountain", "foyer",
+		/* 185 */ "freeze", "front", "full", "furnace", "fuse", "game", "garage", "gas",
+		/* 193 */ "gate", "glass", "go", "good", "goodbye", "great", "group", "guest",
+		/* 201 */ "gun", "hall", "hallway", "hanging-up", "hang-up", "has", "has-expired", "have",
+		/* 209 */ "hear-menu-options", "heat", "help", "high", "hold", "home", "hot", "hottub",
+		/* 217 */ "house", "humidity", "hvac", "if", "immediately", "in", "inches", "increase",
+		/* 225 */ "inner", "input", "inside", "instant", "interior", "in-the", "intruder", "intrusion",
+		/* 233 */ "invalid", "is", "is-about-to-expire", "is-active", "is-armed", "is-canceled", "is-closed", "is-disarmed",
+		/* 241 */ "is-low", "is-off", "is-ok", "is-on", "is-open", "jacuzzi", "jewelry", "keep",
+		/* 249 */ "key", "keypad", "kitchen", "lamp", "laundry", "lawn", "leak", "leave",
+		/* 257 */ "left", "less", "level", "library", "light", "lights", "line", "living-room",
+		/* 265 */ "loading", "lobby", "location", "lock", "low", "lower", "m", "machine",
+		/* 273 */ "mail", "main", "mains", "manual", "master", "max", "media", "medical",
+		/* 281 */ "medicine", "memory", "menu", "message", "middle", "minute", "missing", "mode",
+		/* 289 */ "module", "monitor", "more", "motion", "motor", "next", "night", "no",
+		/* 297 */ "normal", "north", "not", "notified", "now", "number", "nursery", "of",
+		/* 305 */ "off", "office", "oh", "ok", "on", "online", "only", "open",
+		/* 313 */ "operating", "option", "or", "other", "out", "outlet", "output", "outside",
+		/* 321 */ "over", "overhead", "panel", "panic", "parking", "partition", "patio", "pause",
+		/* 329 */ "perimeter", "personal", "phone", "place", "play", "please", "plus", "pm",
+		/* 337 */ "police", "pool", "porch", "port", "pound", "pounds", "power", "press",
+		/* 345 */ "pressure", "problem", "program", "protected", "pump", "radio", "raise", "ready",
+		/* 353 */ "rear", "receiver", "record", "recreation", "relay", "remain-calm", "remote", "repeat",
+		/* 361 */ "report", "reporting", "reset", "restored", "return", "right", "roof", "room",
+		/* 369 */ "running", "safe", "save", "screen", "second", "secure", "security", "select",
+		/* 377 */ "sensor", "serial", "service", "set", "setback", "setpoint", "setting", "shed",
+		/* 385 */ "shipping", "shock", "shop", "shorted", "shunted", "side", "silence", "siren",
+		/* 393 */ "sliding", "smoke", "someone", "south", "spare", "speaker", "sprinkler", "stairs",
+		/* 401 */ "stairway", "star", "start", "status", "stay", "stock", "stop", "storage",
+		/* 409 */ "storm", "studio", "study", "sump", "sun", "switch", "system", "tamper",
+		/* 417 */ "tank", "task", "telephone", "television", "temperature", "test", "thank-you", "that",
+		/* 425 */ "the", "theater", "thermostat", "third", "time", "toggle", "top", "transformer",
+		/* 433 */ "transmitter", "trespassing", "trouble", "turn", "twice", "type", "under", "unit",
+		/* 441 */ "unlocked", "unoccupied", "up", "user", "utility", "vacation", "valve", "video",
+		/* 449 */ "violated", "visitor", "wake-up", "walk", "wall", "warehouse", "warning", "water",
+		/* 457 */ "way", "welcome", "west", "what", "when", "where", "will", "window",
+		/* 465 */ "windows", "with", "work", "yard", "year", "you", "zone", "zones",
+		/* 473 */ "intruder-message",
+	}
+
+	phraseNames = []string{
+		/*   1 */ "silence-delay", "zone-1", "zone-2", "zone-3",
+		/*   5 */ "zone-4", "zone-5", "zone-6", "zone-7",
+		/*   9 */ "zone-8", "zone-9", "zone-10", "zone-11",
+		/*  13 */ "zone-12", "zone-13", "zone-14", "zone-15",
+		/*  17 */ "zone-16", "zone-17", "zone-18", "zone-19",
+		/*  21 */ "zone-20", "zone-21", "zone-22", "zone-23",
+		/*  25 */ "zone-24", "zone-25", "zone-26", "zone-27",
+		/*  29 */ "zone-28", "zone-29", "zone-30", "zone-31",
+		/*  33 */ "zone-32", "zone-33", "zone-34", "zone-35",
+		/*  37 */ "zone-36", "zone-37", "zone-38", "zone-39",
+		/*  41 */ "zone-40", "zone-41", "zone-42", "zone-43",
+		/*  45 */ "zone-44", "zone-45", "zone-46", "zone-47",
+		/*  49 */ "zone-48", "zone-49", "zone-50", "zone-51",
+		/*  53 */ "zone-52", "zone-53", "zone-54", "zone-55",
+		/*  57 */ "zone-56", "zone-57", "zone-58", "zone-59",
+		/*  61 */ "zone-60", "zone-61", "zone-62", "zone-63",
+		/*  65 */ "zone-64", "zone-65", "zone-66", "zone-67",
+		/*  69 */ "zone-68", "zone-69", "zone-70", "zone-71",
+		/*  73 */ "zone-72", "zone-73", "zone-74", "zone-75",
+		/*  77 */ "zone-76", "zone-77", "zone-78", "zone-79",
+		/*  81 */ "zone-80", "zone-81", "zone-82", "zone-83",
+		/*  85 */ "zone-84", "zone-85", "zone-86", "zone-87",
+		/*  89 */ "zone-88", "zone-89", "zone-90", "zone-91",
+		/*  93 */ "zone-92", "zone-93", "zone-94", "zone-95",
+		/*  97 */ "zone-96", "zone-97", "zone-98", "zone-99",
+		/* 101 */ "zone-100", "zone-101", "zone-102", "zone-103",
+		/* 105 */ "zone-104", "zone-105", "zone-106", "zone-107",
+		/* 109 */ "zone-108", "zone-109", "zone-110", "zone-111",
+		/* 113 */ "zone-112", "zone-113", "zone-114", "zone-115",
+		/* 117 */ "zone-116", "zone-117", "zone-118", "zone-119",
+		/* 121 */ "zone-120", "zone-121", "zone-122", "zone-123",
+		/* 125 */ "zone-124", "zone-125", "zone-126", "zone-127",
+		/* 129 */ "zone-128", "zone-129", "zone-130", "zone-131",
+		/* 133 */ "zone-132", "zone-133", "zone-134", "zone-135",
+		/* 137 */ "zone-136", "zone-137", "zone-138", "zone-139",
+		/* 141 */ "zone-140", "zone-141", "zone-142", "zone-143",
+		/* 145 */ "zone-144", "zone-145", "zone-146", "zone-147",
+		/* 149 */ "zone-148", "zone-149", "zone-150", "zone-151",
+		/* 153 */ "zone-152", "zone-153", "zone-154", "zone-155",
+		/* 157 */ "zone-156", "zone-157", "zone-158", "zone-159",
+		/* 161 */ "zone-160", "zone-161", "zone-162", "zone-163",
+		/* 165 */ "zone-164", "zone-165", "zone-166", "zone-167",
+		/* 169 */ "zone-168", "zone-169", "zone-170", "zone-171",
+		/* 173 */ "zone-172", "zone-173", "zone-174", "zone-175",
+		/* 177 */ "zone-176", "zone-177", "zone-178", "zone-179",
+		/* 181 */ "zone-180", "zone-181", "zone-182", "zone-183",
+		/* 185 */ "zone-184", "zone-185", "zone-186", "zone-187",
+		/* 189 */ "zone-188", "zone-189", "zone-190", "zone-191",
+		/* 193 */ "zone-192", "zone-193", "zone-194", "zone-195",
+		/* 197 */ "zone-196", "zone-197", "zone-198", "zone-199",
+		/* 201 */ "zone-200", "zone-201", "zone-202", "zone-203",
+		/* 205 */ "zone-204", "zone-205", "zone-206", "zone-207",
+		/* 209 */ "zone-208", "keypad-panic-alarm", "ac-power-failure", "telephone-line-trouble",
+		/* 213 */ "alarm-silence", "alarm-acknowledged", "area-x-is-armed-away-mode", "area-x-is-armed-stay-mode",
+		/* 217 */ "area-x-is-armed-stay-instant", "area-x-is-armed-night-mode", "area-x-is-armed-night-instant", "area-x-is-armed-vacation-mode",
+		/* 221 */ "area-x-exit-delay-is-about-to-expire", "auto-arm-in-1-minute", "exit-error", "closing-ring-back",
+		/* 225 */ "audio-module-missing", "system-is-armed", "area-x-is-disarmed", "input-expander-missing",
+		/* 229 */ "keypad-missing", "no-zones-violated", "output-expander-missing", "welcome-system-is-on",
+		/* 233 */ "start-module-enrollment", "stop-module-enrollment", "system-battery-is-low", "press-transmitter-button",
+		/* 237 */ "receiver-program-invalid", "test-volume", "say-time", "miscellaneous-1",
+		/* 241 */ "miscellaneous-2", "miscellaneous-3", "miscellaneous-4", "miscellaneous-5",
+		/* 245 */ "miscellaneous-6", "miscellaneous-7", "miscellaneous-8", "miscellaneous-9",
+		/* 249 */ "miscellaneous-10", "enter-pass-code", "access-allowed", "system-not-ready",
+		/* 253 */ "select-task-number", "select-light-number", "select-output-number", "select-temperature-sensor",
+		/* 257 */ "select-keypad-number", "select-thermostat-number", "press-to-change", "press-to-end-message",
+		/* 261 */ "phone-menu-0-hear-menu-options", "phone-menu-1-arm-disarm-status", "phone-menu-2-automation-control", "automation-menu-1-automation-task",
+		/* 265 */ "automation-menu-2-lighting-control", "automation-menu-3-output-control", "automation-menu-4-temperature-sensor", "automation-menu-5-keypad-temperature",
+		/* 269 */ "automation-menu-6-thermostat-temperature", "phone-menu-3-system-summary", "phone-menu-4-zone-status", "phone-menu-7-page",
+		/* 273 */ "phone-menu-8-adjust-volume", "phone-menu-9-exit-and-hangup", "phone-arming", "phone-disarm",
+		/* 277 */ "phone-hangup", "to-turn-on", "to-turn-off", "phone-arm-level-1-away-mode",
+		/* 281 */ "phone-arm-level-2-stay-mode", "phone-arm-level-3-stay-instant-mode", "phone-arm-level-4-night-mode", "phone-arm-level-5-night-instant-mode",
+		/* 285 */ "phone-arm-level-6-vacation-mode", "fire", "medical", "police",
+		/* 289 */ "emergency", "burglary", "carbon-monoxide", "freeze",
+		/* 293 */ "gas", "heat", "water", "auxiliary-1",
+		/* 297 */ "auxiliary-2", "key-switch", "fire-message-1", "fire-message-2",
+		/* 301 */ "burglary-message-1", "burglary-message-2", "alarm", "alarm-memory",
+		/* 305 */ "bypassed", "auto-bypassed", "transmitter-low-battery", "trouble",
+		/* 309 */ "violated", "normal", "on", "off",
+		/* 313 */ "say-name-of-area-1", "say-name-of-area-2", "say-name-of-area-3", "say-name-of-area-4",
+		/* 317 */ "say-name-of-area-5", "say-name-of-area-6", "say-name-of-area-7", "say-name-of-area-8",
+	}
+)
+
+var (
+	vocabularyOnce sync.Once
+	wordNumbers    map[string]int
+	phraseNumbers  map[string]int
+)
+
+func initVocabulary() {
+	index := func(names []string) map[string]int {
+		m := make(map[string]int, len(names))
+		for i, n := range names {
+			if len(n) > 0 {
+				m[n] = i + 1
+			}
+		}
+		return m
+	}
+	wordNumbers = index(wordNames)
+	phraseNumbers = index(phraseNames)
+}
+
+func vocabularyName(names []string, n int) string {
+	if n < 1 || n > len(names) {
+		return ""
+	}
+	return names[n-1]
+}
+
+func parseVocabulary(what string, names []string, numbers map[string]int, name string) (int, error) {
+	if n, ok := numbers[name]; ok {
+		return n, nil
+	}
+	if n, err := strconv.Atoi(name); err == nil && len(vocabularyName(names, n)) > 0 {
+		return n, nil
+	}
+	return 0, fmt.Errorf("unknown %v: %q", what, name)
+}
+
+// WordName returns the name of the specified word, or "" if there
+// is no such word.
+func WordName(word int) string {
+	return vocabularyName(wordNames, word)
+}
+
+// ParseWord returns the number of the word with the specified name or
+// number, eg. "garage" or "191".
+func ParseWord(name string) (int, error) {
+	vocabularyOnce.Do(initVocabulary)
+	return parseVocabulary("word", wordNames, wordNumbers, name)
+}
+
+// PhraseName returns the name of the specified phrase, or "" if there
+// is no such phrase.
+func PhraseName(phrase int) string {
+	return vocabularyName(phraseNames, phrase)
+}
+
+// ParsePhrase returns the number of the phrase with the specified name
+// or number, eg. "system-is-armed" or "226".
+func ParsePhrase(name string) (int, error) {
+	vocabularyOnce.Do(initVocabulary)
+	return parseVocabulary("phrase", phraseNames, phraseNumbers, name)
+}
+
+// SpeakWord speaks the specified word at the M1's voice/siren output.
+func SpeakWord(ctx context.Context, sess *streamconn.Session, word int) error {
+	if len(WordName(word)) == 0 {
+		return fmt.Errorf("invalid word number: %v", word)
+	}
+	return send(ctx, sess, &SpeakWordRequest{Word: word})
+}
+
+// SpeakPhrase speaks the specified phrase at the M1's voice/siren output.
+func SpeakPhrase(ctx context.Context, sess *streamconn.Session, phrase int) error {
+	if len(PhraseName(phrase)) == 0 {
+		return fmt.Errorf("invalid phrase number: %v", phrase)
+	}
+	return send(ctx, sess, &SpeakPhraseRequest{Phrase: phrase})
+}
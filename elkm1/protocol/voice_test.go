@@ -0,0 +1,99 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestVoice(t *testing.T) {
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"09sw12300B7\r\n", &protocol.SpeakWordRequest{Word: 123}},
+		{"09sp12300BE\r\n", &protocol.SpeakPhraseRequest{Phrase: 123}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	for _, tc := range []struct {
+		name string
+		word int
+	}{
+		{"custom1", 1},
+		{"zero", 21},
+		{"200ms-silence", 51},
+		{"garage", 191},
+		{"191", 191},
+		{"open", 312},
+		{"thank-you", 423},
+		{"intruder-message", 473},
+	} {
+		word, err := protocol.ParseWord(tc.name)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if got, want := word, tc.word; got != want {
+			t.Errorf("%v: got %v, want %v", tc.name, got, want)
+		}
+	}
+	for _, name := range []string{"", "garages", "0", "11", "474"} {
+		if _, err := protocol.ParseWord(name); err == nil {
+			t.Errorf("%q: expected an error", name)
+		}
+	}
+	if got, want := protocol.WordName(191), "garage"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		phrase int
+	}{
+		{"silence-delay", 1},
+		{"zone-1", 2},
+		{"system-is-armed", 226},
+		{"say-name-of-area-8", protocol.NumPhrases},
+	} {
+		phrase, err := protocol.ParsePhrase(tc.name)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if got, want := phrase, tc.phrase; got != want {
+			t.Errorf("%v: got %v, want %v", tc.name, got, want)
+		}
+		if got, want := protocol.PhraseName(tc.phrase), tc.name; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if _, err := protocol.ParsePhrase("321"); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	ctx := context.Background()
+	ft, sess := newSession()
+	if err := protocol.SpeakWord(ctx, sess, 191); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := protocol.SpeakPhrase(ctx, sess, 226); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := protocol.SpeakWord(ctx, sess, 15); err == nil {
+		t.Errorf("expected an error for an unimplemented word")
+	}
+	if err := protocol.SpeakPhrase(ctx, sess, 0); err == nil {
+		t.Errorf("expected an error for an invalid phrase")
+	}
+	if got, want := strings.Join(ft.sent, ""), "09sw19100B2\r\n09sp22600BA\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
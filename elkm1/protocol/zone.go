@@ -7,6 +7,7 @@ package protocol
 import (
 	"context"
 	"fmt"
+	"math"
 
 	"github.com/cosnicolaou/automation/net/streamconn"
 )
@@ -106,36 +107,50 @@ func (z ZoneDef) String() string {
 
 type ZoneDefs [NumZones]ZoneDef
 
-func GetZoneDefinitions(ctx context.Context, sess *streamconn.Session) (ZoneDefs, error) {
-	req, resp := request.ZoneDefinitions()
-	data, err := rpc(ctx, sess, req, resp)
-	if err != nil {
-		return ZoneDefs{}, err
+// ZoneDefinitionsRequest (zd) requests the definitions of all zones.
+type ZoneDefinitionsRequest struct{}
+
+func (m *ZoneDefinitionsRequest) Type() string             { return "zd" }
+func (m *ZoneDefinitionsRequest) Encode() []byte           { return nil }
+func (m *ZoneDefinitionsRequest) Decode(data []byte) error { return checkLen("zd", data, 0) }
+
+// ZoneDefinitionsReply (ZD) is the reply to a ZoneDefinitionsRequest.
+type ZoneDefinitionsReply struct {
+	Defs ZoneDefs
+}
+
+func (m *ZoneDefinitionsReply) Type() string { return "ZD" }
+
+func (m *ZoneDefinitionsReply) Encode() []byte {
+	buf := make([]byte, NumZones)
+	for i, d := range m.Defs {
+		buf[i] = byte(d) + '0'
 	}
-	var defs ZoneDefs
+	return buf
+}
+
+func (m *ZoneDefinitionsReply) Decode(data []byte) error {
 	if len(data) != NumZones {
-		return ZoneDefs{}, fmt.Errorf("unexpected number of zones: got %v, expected %v", len(data), NumZones)
+		return fmt.Errorf("unexpected number of zones: got %v, expected %v", len(data), NumZones)
 	}
 	for i := range data {
-		defs[i] = ZoneDef(data[i] - '0')
+		m.Defs[i] = ZoneDef(data[i] - '0')
 	}
-	return defs, nil
+	return nil
 }
 
-func GetZoneName(ctx context.Context, sess *streamconn.Session, zone int) (string, error) {
-	req, resp := request.ZoneName(zone)
-	data, err := rpc(ctx, sess, req, resp)
-	if err != nil {
-		return "", err
-	}
-	zn, name, err := ParseTextDescription(data)
-	if err != nil {
-		return "", err
-	}
-	if zn != zone {
-		return "", fmt.Errorf("unexpected zone: got %v, expected %v", zn, zone)
+func GetZoneDefinitions(ctx context.Context, sess *streamconn.Session) (ZoneDefs, error) {
+	var reply ZoneDefinitionsReply
+	if err := call(ctx, sess, &ZoneDefinitionsRequest{}, &reply); err != nil {
+		return ZoneDefs{}, err
 	}
-	return name, nil
+	return reply.Defs, nil
+}
+
+// GetZoneName returns the name of the specified zone, or an empty
+// string if it has no name.
+func GetZoneName(ctx context.Context, sess *streamconn.Session, zone int) (string, error) {
+	return GetName(ctx, sess, ZoneDescription, zone)
 }
 
 type ZonePhysicalStatus byte
@@ -190,13 +205,386 @@ func (z ZoneStatus) Logical() ZoneLogicalStatus {
 
 type ZoneStatusAll [NumZones]ZoneStatus
 
+// ZoneStatusRequest (zs) requests the status of all zones.
+type ZoneStatusRequest struct{}
+
+func (m *ZoneStatusRequest) Type() string             { return "zs" }
+func (m *ZoneStatusRequest) Encode() []byte           { return nil }
+func (m *ZoneStatusRequest) Decode(data []byte) error { return checkLen("zs", data, 0) }
+
+// ZoneStatusReply (ZS) is the reply to a ZoneStatusRequest.
+type ZoneStatusReply struct {
+	Status ZoneStatusAll
+}
+
+func (m *ZoneStatusReply) Type() string { return "ZS" }
+
+func (m *ZoneStatusReply) Encode() []byte {
+	buf := make([]byte, NumZones)
+	for i, s := range m.Status {
+		buf[i] = hexLookup[s&0x0f]
+	}
+	return buf
+}
+
+func (m *ZoneStatusReply) Decode(data []byte) error {
+	if err := checkLen("zone status", data, NumZones); err != nil {
+		return err
+	}
+	for i, s := range data {
+		m.Status[i] = ZoneStatus(readHexDigit(s))
+	}
+	return nil
+}
+
+// ZoneChange (ZC) is sent by the M1, if so configured, whenever
+// the status of a zone changes.
+type ZoneChange struct {
+	Zone   int
+	Status ZoneStatus
+}
+
+func (m *ZoneChange) Type() string { return "ZC" }
+
+func (m *ZoneChange) Encode() []byte {
+	return append(appendDecInt(nil, m.Zone, 3), hexLookup[m.Status&0x0f])
+}
+
+func (m *ZoneChange) Decode(data []byte) error {
+	if err := checkLen("zone change", data, 4); err != nil {
+		return err
+	}
+	zone, data, err := readDecIntN(data, 3)
+	if err != nil {
+		return err
+	}
+	m.Zone, m.Status = zone, ZoneStatus(readHexDigit(data[0]))
+	return nil
+}
+
 // GetZoneStatusAll returns the status of all zones, it should not be used for
 // polling.
 func GetZoneStatusAll(ctx context.Context, sess *streamconn.Session) (ZoneStatusAll, error) {
-	req, resp := request.ZoneStatus()
-	data, err := rpc(ctx, sess, req, resp)
-	if err != nil {
+	var reply ZoneStatusReply
+	if err := call(ctx, sess, &ZoneStatusRequest{}, &reply); err != nil {
 		return ZoneStatusAll{}, err
 	}
-	return ParseZoneStatus(data)
+	return reply.Status, nil
+}
+
+// AlarmByZoneRequest (az) requests the alarm state of all zones.
+type AlarmByZoneRequest struct{}
+
+func (m *AlarmByZoneRequest) Type() string             { return "az" }
+func (m *AlarmByZoneRequest) Encode() []byte           { return nil }
+func (m *AlarmByZoneRequest) Decode(data []byte) error { return checkLen("az", data, 0) }
+
+// ZoneAlarms records the zones in alarm, indexed by zone number minus
+// one. Each zone in alarm records its zone definition, which determines
+// the type of the alarm, and DisabledZoneType if it is not in alarm.
+type ZoneAlarms [NumZones]ZoneDef
+
+// AlarmByZoneReply (AZ) is the reply to an AlarmByZoneRequest. A zone
+// remains in alarm until a valid user code is entered to acknowledge
+// the alarm.
+type AlarmByZoneReply struct {
+	Alarms ZoneAlarms
+}
+
+func (m *AlarmByZoneReply) Type() string { return "AZ" }
+
+func (m *AlarmByZoneReply) Encode() []byte {
+	buf := make([]byte, NumZones)
+	for i, d := range m.Alarms {
+		buf[i] = byte(d) + '0'
+	}
+	return buf
+}
+
+func (m *AlarmByZoneReply) Decode(data []byte) error {
+	if err := checkLen("alarm by zone", data, NumZones); err != nil {
+		return err
+	}
+	for i := range data {
+		m.Alarms[i] = ZoneDef(data[i] - '0')
+	}
+	return nil
+}
+
+// GetAlarmsByZone returns the alarm state of all zones.
+func GetAlarmsByZone(ctx context.Context, sess *streamconn.Session) (ZoneAlarms, error) {
+	var reply AlarmByZoneReply
+	if err := call(ctx, sess, &AlarmByZoneRequest{}, &reply); err != nil {
+		return ZoneAlarms{}, err
+	}
+	return reply.Alarms, nil
+}
+
+// Alarm returns the type of alarm raised by a zone with definition z, or
+// AreaNoAlarmActive for definitions that do not raise alarms.
+func (z ZoneDef) Alarm() AlarmState {
+	switch z {
+	case BurglarEntryExit1, BurglarEntryExit2, BurglarPerimeterInstant,
+		BurglarInterior, BurglarInteriorFollower, BurglarInteriorNight,
+		BurglarInteriorNightDelay, Burglar24Hour, BurglarBoxTamper:
+		return AreaBurglarAlarm
+	case FireAlarm:
+		return AreaFireAlarm
+	case FireVerified:
+		return AreaVerifyFireAlarm
+	case FireSupervisory:
+		return AreaFireSupervisoryAlarm
+	case AuxAlarm1:
+		return AreaAux1Alarm
+	case AuxAlarm2:
+		return AreaAux2Alarm
+	case CarbonMonoxide:
+		return AreaCarbonMonoxideAlarm
+	case EmergencyAlarm:
+		return AreaEmergencyAlarm
+	case FreezeAlarm:
+		return AreaFreezeAlarm
+	case GasAlarm:
+		return AreaGasAlarm
+	case HeatAlarm:
+		return AreaHeatAlarm
+	case MedicalAlarm:
+		return AreaMedicalAlarm
+	case PoliceAlarm, PoliceNoIndication:
+		return AreaPoliceAlarm
+	case WaterAlarm:
+		return AreaWaterAlarm
+	}
+	return AreaNoAlarmActive
+}
+
+const (
+	// UnbypassAllZones is used as the zone number in a ZoneBypassRequest
+	// to unbypass all burglar zones in an area.
+	UnbypassAllZones = 0
+	// BypassViolatedZones is used as the zone number in a ZoneBypassRequest
+	// to bypass all violated burglar zones in an area.
+	BypassViolatedZones = 999
+)
+
+// ZoneBypassRequest (zb) toggles the bypass state of a zone, or for
+// UnbypassAllZones and BypassViolatedZones, of all of the burglar zones
+// in the specified area.
+type ZoneBypassRequest struct {
+	Zone int
+	Area int
+	Code string
+}
+
+func (m *ZoneBypassRequest) Type() string { return "zb" }
+
+func (m *ZoneBypassRequest) Encode() []byte {
+	buf := appendDecInt(appendDecInt(nil, m.Zone, 3), m.Area, 1)
+	return append(buf, fmt.Sprintf("%06s", m.Code)...)
+}
+
+func (m *ZoneBypassRequest) Decode(data []byte) error {
+	if err := checkLen("zb", data, 3+1+UserCodeLen); err != nil {
+		return err
+	}
+	var err error
+	if m.Zone, data, err = readDecIntN(data, 3); err != nil {
+		return err
+	}
+	if m.Area, data, err = readDecIntN(data, 1); err != nil {
+		return err
+	}
+	m.Code = string(data)
+	return nil
+}
+
+// ZoneBypassReply (ZB) is the reply to a ZoneBypassRequest.
+type ZoneBypassReply struct {
+	Zone     int
+	Bypassed bool
+}
+
+func (m *ZoneBypassReply) Type() string { return "ZB" }
+
+func (m *ZoneBypassReply) Encode() []byte {
+	return append(appendDecInt(nil, m.Zone, 3), boolDigit(m.Bypassed))
+}
+
+func (m *ZoneBypassReply) Decode(data []byte) error {
+	if err := checkLen("zone bypass", data, 4); err != nil {
+		return err
+	}
+	zone, data, err := readDecIntN(data, 3)
+	if err != nil {
+		return err
+	}
+	m.Zone, m.Bypassed = zone, data[0] == '1'
+	return nil
+}
+
+func validateZone(zone int) error {
+	if zone < 1 || zone > NumZones {
+		return fmt.Errorf("invalid zone number: %v", zone)
+	}
+	return nil
+}
+
+// ToggleZoneBypass toggles the bypass state of the specified zone and
+// returns its new state. The user code must be valid in the area that
+// the zone belongs to.
+func ToggleZoneBypass(ctx context.Context, sess *streamconn.Session, zone, area int, code string) (bool, error) {
+	if err := validateZone(zone); err != nil {
+		return false, err
+	}
+	if err := validateArea(area); err != nil {
+		return false, err
+	}
+	if err := validateUserCode(code); err != nil {
+		return false, err
+	}
+	var reply ZoneBypassReply
+	if err := callSensitive(ctx, sess, &ZoneBypassRequest{Zone: zone, Area: area, Code: code}, &reply); err != nil {
+		return false, err
+	}
+	if reply.Zone != zone {
+		return false, fmt.Errorf("unexpected zone: got %v, expected %v", reply.Zone, zone)
+	}
+	return reply.Bypassed, nil
+}
+
+// SetZoneBypass bypasses or unbypasses the specified zone. Since the
+// zb command toggles the bypass state, the zone's current state is
+// obtained first and the command is only sent if a change is required.
+// The state returned in the ZB reply is used to verify the change. The
+// user code must be valid in the area that the zone belongs to.
+func SetZoneBypass(ctx context.Context, sess *streamconn.Session, zone, area int, bypass bool, code string) error {
+	if err := validateZone(zone); err != nil {
+		return err
+	}
+	status, err := GetZoneStatusAll(ctx, sess)
+	if err != nil {
+		return err
+	}
+	if (status[zone-1].Logical() == ZoneBypassed) == bypass {
+		return nil
+	}
+	bypassed, err := ToggleZoneBypass(ctx, sess, zone, area, code)
+	if err != nil {
+		return err
+	}
+	if bypassed != bypass {
+		return fmt.Errorf("zone %v: bypass state is %v, expected %v", zone, bypassed, bypass)
+	}
+	return nil
+}
+
+// BypassAreaZones bypasses all violated burglar zones in the specified
+// area if bypass is true, and unbypasses all burglar zones otherwise.
+func BypassAreaZones(ctx context.Context, sess *streamconn.Session, area int, bypass bool, code string) (ZoneBypassReply, error) {
+	if err := validateArea(area); err != nil {
+		return ZoneBypassReply{}, err
+	}
+	if err := validateUserCode(code); err != nil {
+		return ZoneBypassReply{}, err
+	}
+	zone := UnbypassAllZones
+	if bypass {
+		zone = BypassViolatedZones
+	}
+	var reply ZoneBypassReply
+	if err := callSensitive(ctx, sess, &ZoneBypassRequest{Zone: zone, Area: area, Code: code}, &reply); err != nil {
+		return ZoneBypassReply{}, err
+	}
+	if reply.Zone != zone {
+		return ZoneBypassReply{}, fmt.Errorf("unexpected zone: got %v, expected %v", reply.Zone, zone)
+	}
+	return reply, nil
+}
+
+// ZoneTriggerRequest (zt) creates a virtual momentary open condition on
+// a zone, as if its EOL hardwired loop had been opened. M1 Ver. 4.5.23,
+// 5.1.23 or later.
+type ZoneTriggerRequest struct {
+	Zone int
+}
+
+func (m *ZoneTriggerRequest) Type() string   { return "zt" }
+func (m *ZoneTriggerRequest) Encode() []byte { return appendDecInt(nil, m.Zone, 3) }
+
+func (m *ZoneTriggerRequest) Decode(data []byte) error {
+	if err := checkLen("zt", data, 3); err != nil {
+		return err
+	}
+	var err error
+	m.Zone, _, err = readDecIntN(data, 3)
+	return err
+}
+
+// TriggerZone momentarily violates the specified zone.
+func TriggerZone(ctx context.Context, sess *streamconn.Session, zone int) error {
+	if err := validateZone(zone); err != nil {
+		return err
+	}
+	return send(ctx, sess, &ZoneTriggerRequest{Zone: zone})
+}
+
+// ZoneVoltageRequest (zv) requests the analog voltage of a zone,
+// M1 Ver. 4.2.8 and later.
+type ZoneVoltageRequest struct {
+	Zone int
+}
+
+func (m *ZoneVoltageRequest) Type() string   { return "zv" }
+func (m *ZoneVoltageRequest) Encode() []byte { return appendDecInt(nil, m.Zone, 3) }
+
+func (m *ZoneVoltageRequest) Decode(data []byte) error {
+	if err := checkLen("zv", data, 3); err != nil {
+		return err
+	}
+	var err error
+	m.Zone, _, err = readDecIntN(data, 3)
+	return err
+}
+
+// ZoneVoltageReply (ZV) is the reply to a ZoneVoltageRequest.
+type ZoneVoltageReply struct {
+	Zone  int
+	Volts float64
+}
+
+func (m *ZoneVoltageReply) Type() string { return "ZV" }
+
+func (m *ZoneVoltageReply) Encode() []byte {
+	return appendDecInt(appendDecInt(nil, m.Zone, 3), int(math.Round(m.Volts*10)), 3)
+}
+
+func (m *ZoneVoltageReply) Decode(data []byte) error {
+	if err := checkLen("zone voltage", data, 3+3); err != nil {
+		return err
+	}
+	var err error
+	if m.Zone, data, err = readDecIntN(data, 3); err != nil {
+		return err
+	}
+	tenths, _, err := readDecIntN(data, 3)
+	if err != nil {
+		return err
+	}
+	m.Volts = float64(tenths) / 10
+	return nil
+}
+
+// GetZoneVoltage returns the analog voltage of the specified zone.
+func GetZoneVoltage(ctx context.Context, sess *streamconn.Session, zone int) (float64, error) {
+	if err := validateZone(zone); err != nil {
+		return 0, err
+	}
+	var reply ZoneVoltageReply
+	if err := call(ctx, sess, &ZoneVoltageRequest{Zone: zone}, &reply); err != nil {
+		return 0, err
+	}
+	if reply.Zone != zone {
+		return 0, fmt.Errorf("unexpected zone: got %v, expected %v", reply.Zone, zone)
+	}
+	return reply.Volts, nil
 }
@@ -0,0 +1,136 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func zoneStatusMsg(zone int, status protocol.ZoneStatus) string {
+	var reply protocol.ZoneStatusReply
+	reply.Status[zone-1] = status
+	return string(protocol.Encode(&reply))
+}
+
+func TestZoneBypass(t *testing.T) {
+	for _, tc := range []struct {
+		msg     string
+		decoded protocol.Message
+	}{
+		{"10zb0051003456006B\r\n", &protocol.ZoneBypassRequest{Zone: 5, Area: 1, Code: "003456"}},
+		{"0AZB123100CC\r\n", &protocol.ZoneBypassReply{Zone: 123, Bypassed: true}},
+		{"09zt12300B3\r\n", &protocol.ZoneTriggerRequest{Zone: 123}},
+		{"09zv12300B1\r\n", &protocol.ZoneVoltageRequest{Zone: 123}},
+		{"0CZV123072004E\r\n", &protocol.ZoneVoltageReply{Zone: 123, Volts: 7.2}},
+	} {
+		roundTrip(t, tc.msg, tc.decoded)
+	}
+
+	ctx := context.Background()
+	ft, sess := newSession(zoneStatusMsg(5, 0x2), "0AZB005100CD\r\n")
+	defer sess.Release()
+	if err := protocol.SetZoneBypass(ctx, sess, 5, 1, true, "3456"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := strings.Join(ft.sent, ""), "06zs004D\r\n10zb0051003456006B\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Already bypassed, so no zb request is sent.
+	ft, sess = newSession(zoneStatusMsg(5, 0xE))
+	defer sess.Release()
+	if err := protocol.SetZoneBypass(ctx, sess, 5, 1, true, "3456"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := len(ft.sent), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The ZB reply reports that the zone is still bypassed.
+	_, sess = newSession(zoneStatusMsg(5, 0xE), "0AZB005100CD\r\n")
+	defer sess.Release()
+	if err := protocol.SetZoneBypass(ctx, sess, 5, 1, false, "3456"); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	// The user code is checked against the zone's area.
+	ft, sess = newSession(zoneStatusMsg(5, 0x2), "0AZB005100CD\r\n")
+	defer sess.Release()
+	if err := protocol.SetZoneBypass(ctx, sess, 5, 3, true, "3456"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := ft.sent[1], "10zb00530034560069\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := protocol.SetZoneBypass(ctx, sess, 5, 0, true, "3456"); err == nil {
+		t.Errorf("expected an error for an invalid area")
+	}
+
+	ft, sess = newSession("0AZB999100B7\r\n", "0AZB000000D3\r\n")
+	defer sess.Release()
+	reply, err := protocol.BypassAreaZones(ctx, sess, 2, true, "3456")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := reply, (protocol.ZoneBypassReply{Zone: protocol.BypassViolatedZones, Bypassed: true}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := ft.sent[0], "10zb99920034560054\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// The ZB reply is for a different request.
+	if _, err := protocol.BypassAreaZones(ctx, sess, 2, true, "3456"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestAlarmByZone(t *testing.T) {
+	spec := "D6AZ00000000900000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000082\r\n"
+	alarms := "D6AZ1000000000000000:00F00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000006A\r\n"
+	var want protocol.AlarmByZoneReply
+	want.Alarms[8] = protocol.BurglarBoxTamper
+	roundTrip(t, spec, &want)
+	want = protocol.AlarmByZoneReply{}
+	want.Alarms[0] = protocol.BurglarEntryExit1
+	want.Alarms[16] = protocol.FireAlarm
+	want.Alarms[19] = protocol.MedicalAlarm
+	roundTrip(t, alarms, &want)
+
+	ctx := context.Background()
+	ft, sess := newSession(alarms)
+	za, err := protocol.GetAlarmsByZone(ctx, sess)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for i, d := range za {
+		if d != protocol.DisabledZoneType {
+			got = append(got, fmt.Sprintf("%v:%v", i+1, d.Alarm()))
+		}
+	}
+	if got, want := strings.Join(got, ","), "1:Burglar Alarm,17:Fire Alarm,20:Medical Alarm"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, tc := range []struct {
+		def   protocol.ZoneDef
+		alarm protocol.AlarmState
+	}{
+		{protocol.NonAlarm, protocol.AreaNoAlarmActive},
+		{protocol.FireAlarm, protocol.AreaFireAlarm},
+		{protocol.FireVerified, protocol.AreaVerifyFireAlarm},
+	} {
+		if got, want := tc.def.Alarm(), tc.alarm; got != want {
+			t.Errorf("%v: got %v, want %v", tc.def, got, want)
+		}
+	}
+	if got, want := strings.Join(ft.sent, ""), "06az005F\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloudeng.io/logging/ctxlog"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+// AlarmReceiver is called for every AR alarm report sent by the M1. It
+// should return an error if the report could not be delivered, in which
+// case the M1 is told that the report failed rather than that it was
+// received. Receivers are called by the connection's reader as each report
+// arrives and so must return promptly and must not issue requests to the
+// M1 since the reader cannot deliver their replies until they return.
+type AlarmReceiver func(ctx context.Context, report protocol.AlarmReport) error
+
+// alarmReports acknowledges the AR alarm reports sent by the M1 once
+// they have been handed to the configured receiver.
+type alarmReports struct {
+	mu       sync.Mutex
+	receiver AlarmReceiver
+	// stop stops the goroutine that keeps the connection open whilst
+	// a receiver is set.
+	stop func()
+}
+
+// handle replies to every AR report with exactly one ar acknowledgement
+// if it was delivered to the receiver, or one ax otherwise.
+func (ar *alarmReports) handle(ctx context.Context, f protocol.Frame) {
+	report, err := ar.deliver(ctx, f)
+	if err != nil {
+		ctxlog.Info(ctx, "elk-m1: alarm report not delivered", "data", string(f.Data), "err", err)
+		if err := protocol.FailAlarmReport(ctx); err != nil {
+			ctxlog.Info(ctx, "elk-m1: failed to fail alarm report", "data", string(f.Data), "err", err)
+		}
+		return
+	}
+	ctxlog.Info(ctx, "elk-m1: alarm report delivered", "report", report.String(), "code", report.AlarmCode())
+	if err := protocol.AcknowledgeAlarmReport(ctx); err != nil {
+		ctxlog.Info(ctx, "elk-m1: failed to acknowledge alarm report", "report", report.String(), "err", err)
+	}
+}
+
+func (ar *alarmReports) deliver(ctx context.Context, f protocol.Frame) (protocol.AlarmReport, error) {
+	ar.mu.Lock()
+	receiver := ar.receiver
+	ar.mu.Unlock()
+	var report protocol.AlarmReport
+	if err := report.Decode(f.Data); err != nil {
+		return report, err
+	}
+	if receiver == nil {
+		return report, fmt.Errorf("%v: no alarm receiver is configured", report)
+	}
+	if err := receiver(ctx, report); err != nil {
+		return report, fmt.Errorf("%v: %w", report, err)
+	}
+	return report, nil
+}
+
+// SetAlarmReceiver sets the receiver for the AR alarm reports sent by the
+// M1 when it is configured to report alarms via IP. Reports are
+// acknowledged, using ar, once the receiver returns successfully and are
+// failed, using ax, otherwise, including when no receiver is set, so that
+// the M1 falls back to its other reporting paths, eg. the telephone dialer.
+// Whilst a receiver is set the connection to the M1XEP is kept open,
+// rather than being closed when idle for keep_alive, and is re-established
+// whenever it is lost, so that reports are received as they are sent.
+// Setting a nil receiver allows the connection to be closed when idle.
+func (m1 *M1xep) SetAlarmReceiver(r AlarmReceiver) {
+	if r == nil {
+		m1.alarms.mu.Lock()
+		m1.alarms.receiver = nil
+		m1.alarms.mu.Unlock()
+		m1.alarms.stopKeepingConnected()
+		return
+	}
+	m1.alarms.mu.Lock()
+	defer m1.alarms.mu.Unlock()
+	m1.alarms.receiver = r
+	if m1.alarms.stop == nil {
+		ctx, cancel := context.WithCancel(ctxlog.WithAttributes(context.Background(), "protocol", "elk-m1xep"))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			m1.keepConnected(ctx)
+		}()
+		m1.alarms.stop = func() {
+			cancel()
+			<-done
+		}
+	}
+}
+
+// stopKeepingConnected stops the goroutine, if any, that keeps the
+// connection open and waits for it to finish.
+func (ar *alarmReports) stopKeepingConnected() {
+	ar.mu.Lock()
+	stop := ar.stop
+	ar.stop = nil
+	ar.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// keepConnected keeps the connection to the M1XEP, and hence its reader,
+// open until ctx is canceled by resetting its idle timer every half of
+// keep_alive. The connection is closed and re-established whenever its
+// reader stops, and retried every half of keep_alive if it can't be.
+func (m1 *M1xep) keepConnected(ctx context.Context) {
+	interval := m1.ControllerConfigCustom.KeepAlive / 2
+	if interval <= 0 {
+		ctxlog.Info(ctx, "elk-m1: alarm receiver: keep_alive is not configured, alarm reports are only received whilst connected")
+		return
+	}
+	for {
+		err := m1.holdConnection(ctx, interval)
+		if ctx.Err() != nil {
+			return
+		}
+		ctxlog.Info(ctx, "elk-m1: alarm receiver: connection to the M1XEP is down, alarm reports will not be received", "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// holdConnection establishes a connection and resets its idle timer
+// every interval until ctx is canceled or its reader stops.
+func (m1 *M1xep) holdConnection(ctx context.Context, interval time.Duration) error {
+	sctx, sess, idle, err := m1.sessionWithIdle(ctx)
+	if err != nil {
+		return err
+	}
+	sess.Release()
+	ctxlog.Info(sctx, "elk-m1: alarm receiver: connected to the M1XEP")
+	for {
+		// Reset immediately since establishing the session, including
+		// detecting the firmware version, may have taken a while.
+		idle.Reset(ctx)
+		wctx, cancel := context.WithTimeout(ctx, interval)
+		err := m1.dispatcher.Wait(wctx)
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			continue
+		}
+		// The reader has stopped and hence the connection is no longer
+		// usable, close it so that the next session re-establishes it.
+		if cerr := m1.ondemand.Close(ctx); cerr != nil {
+			ctxlog.Info(ctx, "elk-m1: alarm receiver: failed to close connection", "err", cerr)
+		}
+		return err
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+// pipeTransport returns the lines written to its lines channel, blocking
+// until one is available, records every message sent to it and calls
+// onSend, if set, for each of them.
+type pipeTransport struct {
+	lines  chan string
+	mu     sync.Mutex
+	sent   []string
+	onSend func(string)
+}
+
+func newPipeTransport() *pipeTransport {
+	return &pipeTransport{lines: make(chan string, 10)}
+}
+
+func (pt *pipeTransport) Send(_ context.Context, buf []byte) (int, error) {
+	pt.mu.Lock()
+	pt.sent = append(pt.sent, string(buf))
+	onSend := pt.onSend
+	pt.mu.Unlock()
+	if onSend != nil {
+		onSend(string(buf))
+	}
+	return len(buf), nil
+}
+
+func (pt *pipeTransport) SendSensitive(ctx context.Context, buf []byte) (int, error) {
+	return pt.Send(ctx, buf)
+}
+
+func (pt *pipeTransport) ReadUntil(ctx context.Context, _ []string) ([]byte, error) {
+	select {
+	case l, ok := <-pt.lines:
+		if !ok {
+			return nil, io.EOF
+		}
+		return []byte(l), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (pt *pipeTransport) Close(context.Context) error {
+	return nil
+}
+
+// waitForSent waits for n messages to have been sent and returns them.
+func (pt *pipeTransport) waitForSent(t *testing.T, n int) []string {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(time.Millisecond) {
+		pt.mu.Lock()
+		sent := slices.Clone(pt.sent)
+		pt.mu.Unlock()
+		if len(sent) >= n {
+			return sent
+		}
+	}
+	t.Fatalf("timed out waiting for %v messages to be sent", n)
+	return nil
+}
+
+// startReader starts m1's reader on a new pipeTransport.
+func startReader(m1 *M1xep) (*pipeTransport, <-chan error) {
+	pt := newPipeTransport()
+	return pt, m1.Dispatcher().Start(context.Background(), pt, time.Minute)
+}
+
+func TestAlarmReports(t *testing.T) {
+	m1 := NewM1XEP(devices.Options{})
+	pt, errCh := startReader(m1)
+
+	const (
+		report  = "16AR12345611340100110085\r\n"
+		invalid = "16AR1234561X34010011005E\r\n"
+		ack     = "06ar0067\r\n"
+		fail    = "06ax0061\r\n"
+	)
+
+	var received []protocol.AlarmReport
+	var receiverErr error
+	receiver := func(_ context.Context, r protocol.AlarmReport) error {
+		received = append(received, r)
+		return receiverErr
+	}
+
+	sent := 0
+	for i, tc := range []struct {
+		msg      string
+		err      error
+		receiver bool
+		reply    string
+	}{
+		{report, nil, true, ack},
+		{report, fmt.Errorf("oops"), true, fail},
+		{report, nil, false, fail},
+		{invalid, nil, true, fail},
+		{report, nil, true, ack},
+	} {
+		receiverErr = tc.err
+		m1.SetAlarmReceiver(nil)
+		if tc.receiver {
+			m1.SetAlarmReceiver(receiver)
+		}
+		pt.lines <- tc.msg
+		msgs := pt.waitForSent(t, sent+1)
+		sent++
+		if got, want := msgs[len(msgs)-1], tc.reply; got != want {
+			t.Errorf("%v: got %q, want %q", i, got, want)
+		}
+	}
+	close(pt.lines)
+	if err := <-errCh; err != io.EOF {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// Exactly one reply is sent for every report.
+	if got, want := len(pt.waitForSent(t, sent)), sent; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(received), 3; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := received[0].AlarmCode(), "1134"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// acceptM1XEP accepts connections on l and returns the lines read from
+// each of them.
+func acceptM1XEP(l net.Listener) <-chan fakeConn {
+	ch := make(chan fakeConn, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			lines := make(chan string, 100)
+			go func() {
+				defer close(lines)
+				sc := bufio.NewScanner(conn)
+				for sc.Scan() {
+					lines <- sc.Text()
+				}
+			}()
+			ch <- fakeConn{conn, lines}
+		}
+	}()
+	return ch
+}
+
+// newTestM1XEP returns an M1xep that connects to the M1XEP listening on l.
+func newTestM1XEP(l net.Listener, keepAlive time.Duration) *M1xep {
+	m1 := NewM1XEP(devices.Options{})
+	m1.ControllerConfigCustom.IPAddress = l.Addr().String()
+	m1.ControllerConfigCustom.KeepAlive = keepAlive
+	m1.ondemand.SetKeepAlive(keepAlive)
+	m1.Timeout = 50 * time.Millisecond
+	return m1
+}
+
+type fakeConn struct {
+	net.Conn
+	lines <-chan string
+}
+
+// waitForLine waits for line to be sent on the connection, ignoring
+// all other lines, eg. vn requests.
+func (fc fakeConn) waitForLine(t *testing.T, line string) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case l, ok := <-fc.lines:
+			if !ok {
+				t.Fatalf("connection closed whilst waiting for %q", line)
+			}
+			if l == line {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %q", line)
+		}
+	}
+}
+
+func TestAlarmReportsKeepConnected(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conns := acceptM1XEP(l)
+	nextConn := func() fakeConn {
+		t.Helper()
+		select {
+		case fc := <-conns:
+			return fc
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for a connection")
+		}
+		return fakeConn{}
+	}
+
+	const keepAlive = 200 * time.Millisecond
+	m1 := newTestM1XEP(l, keepAlive)
+	defer m1.Close(context.Background())
+
+	received := make(chan protocol.AlarmReport, 10)
+	m1.SetAlarmReceiver(func(_ context.Context, r protocol.AlarmReport) error {
+		received <- r
+		return nil
+	})
+
+	report := func(fc fakeConn) {
+		t.Helper()
+		if _, err := fc.Write([]byte("16AR12345611340100110085\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case r := <-received:
+			if got, want := r.AlarmCode(), "1134"; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for an alarm report")
+		}
+		fc.waitForLine(t, "06ar0067")
+	}
+
+	// The connection is kept open well beyond the keep alive period.
+	fc := nextConn()
+	time.Sleep(5 * keepAlive)
+	report(fc)
+	select {
+	case <-conns:
+		t.Errorf("the connection should not have been re-established")
+	default:
+	}
+
+	// The connection is re-established if it is lost.
+	fc.Close()
+	report(nextConn())
+
+	// Without a receiver the connection is closed when idle.
+	m1.SetAlarmReceiver(nil)
+}
@@ -0,0 +1,195 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+	"gopkg.in/yaml.v3"
+)
+
+// taskActivations records the time at which each task was most recently
+// activated as reported by TC messages.
+type taskActivations struct {
+	mu        sync.Mutex
+	activated map[int]time.Time
+}
+
+func newTaskActivations() *taskActivations {
+	return &taskActivations{activated: map[int]time.Time{}}
+}
+
+func (ta *taskActivations) handle(_ context.Context, f protocol.Frame) {
+	var tc protocol.TaskChange
+	if err := tc.Decode(f.Data); err != nil {
+		return
+	}
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+	ta.activated[tc.Task] = time.Now()
+}
+
+func (ta *taskActivations) lastActivated(task int) (time.Time, bool) {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+	t, ok := ta.activated[task]
+	return t, ok
+}
+
+// resolveTask returns the task number for spec, which may be either
+// a task number or the task's text name.
+func resolveTask(ctx context.Context, sess *streamconn.Session, spec string) (int, error) {
+	if task, err := strconv.Atoi(spec); err == nil {
+		return task, nil
+	}
+	return protocol.FindTask(ctx, sess, spec)
+}
+
+type TaskConfig struct {
+	TaskNumber int    `yaml:"task"`
+	TaskName   string `yaml:"name"`
+}
+
+type Task struct {
+	m1DeviceBase
+	devices.DeviceBase[TaskConfig]
+
+	mu   sync.Mutex
+	task int
+}
+
+func NewTask(_ devices.Options) *Task {
+	return &Task{
+		m1DeviceBase: m1DeviceBase{},
+	}
+}
+
+func (t *Task) UnmarshalYAML(node *yaml.Node) error {
+	if err := node.Decode(&t.DeviceConfigCustom); err != nil {
+		return err
+	}
+	cfg := t.DeviceConfigCustom
+	switch {
+	case cfg.TaskNumber != 0 && cfg.TaskName != "":
+		return fmt.Errorf("only one of task or name may be specified")
+	case cfg.TaskName != "":
+	case cfg.TaskNumber < 1 || cfg.TaskNumber > protocol.NumTasks:
+		return fmt.Errorf("invalid task number: %v", cfg.TaskNumber)
+	}
+	t.task = cfg.TaskNumber
+	return nil
+}
+
+func (t *Task) Operations() map[string]devices.Operation {
+	return map[string]devices.Operation{
+		"activate": t.Activate,
+	}
+}
+
+func (t *Task) OperationsHelp() map[string]string {
+	return map[string]string{
+		"activate": "activate the task",
+	}
+}
+
+func (t *Task) Conditions() map[string]devices.Condition {
+	return map[string]devices.Condition{
+		"activated": t.Activated,
+	}
+}
+
+func (t *Task) ConditionsHelp() map[string]string {
+	return map[string]string{
+		"activated": "true if the task was activated within the specified duration (default 1m), requires task change updates to be enabled on the M1",
+	}
+}
+
+// taskNumber returns the configured task number, looking it up by name
+// the first time it is required if a name was configured.
+func (t *Task) taskNumber(ctx context.Context, sess *streamconn.Session) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.task != 0 {
+		return t.task, nil
+	}
+	task, err := protocol.FindTask(ctx, sess, t.DeviceConfigCustom.TaskName)
+	if err != nil {
+		return 0, err
+	}
+	t.task = task
+	return task, nil
+}
+
+func (t *Task) Activate(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	return t.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, opts devices.OperationArgs) (any, error) {
+		task, err := t.taskNumber(ctx, sess)
+		if err != nil {
+			return nil, err
+		}
+		if err := protocol.ActivateTask(ctx, sess, task); err != nil {
+			return nil, err
+		}
+		if t.logger != nil {
+			t.logger.Info("task-activated", "task", task)
+		}
+		return TaskInfo{Task: task}, nil
+	}, opts)
+}
+
+func (t *Task) Activated(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	within := time.Minute
+	if len(opts.Args) > 0 {
+		d, err := time.ParseDuration(opts.Args[0])
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid duration: %v: %w", opts.Args[0], err)
+		}
+		within = d
+	}
+	task, err := t.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, _ devices.OperationArgs) (any, error) {
+		return t.taskNumber(ctx, sess)
+	}, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	when, ok := t.m1.tasks.lastActivated(task.(int))
+	if !ok {
+		return nil, false, nil
+	}
+	return when, time.Since(when) <= within, nil
+}
+
+type TaskInfo struct {
+	Task          int    `json:"task"`
+	LastActivated string `json:"last_activated,omitempty"`
+}
+
+func (m1 *M1xep) activateTask(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	if len(args.Args) == 0 {
+		return nil, fmt.Errorf("task requires a task number or name")
+	}
+	task, err := resolveTask(ctx, sess, strings.Join(args.Args, " "))
+	if err != nil {
+		return nil, err
+	}
+	if err := protocol.ActivateTask(ctx, sess, task); err != nil {
+		return nil, err
+	}
+	ti := TaskInfo{Task: task}
+	if when, ok := m1.tasks.lastActivated(task); ok {
+		ti.LastActivated = when.String()
+		fmt.Fprintf(args.Writer, "task %v: activated, previously activated at %v\n", task, when)
+	} else {
+		fmt.Fprintf(args.Writer, "task %v: activated\n", task)
+	}
+	return ti, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+type TemperatureInfo struct {
+	Group       string `json:"group"`
+	Device      int    `json:"device"`
+	Temperature int    `json:"temperature"`
+}
+
+func appendTemperatures(temps []TemperatureInfo, group protocol.TemperatureGroup, readings []int) []TemperatureInfo {
+	for i, t := range readings {
+		if group.Valid(t) {
+			temps = append(temps, TemperatureInfo{Group: group.String(), Device: i + 1, Temperature: t})
+		}
+	}
+	return temps
+}
+
+// temperatures returns the temperatures requested by args: no arguments
+// returns all keypad and zone probe temperatures, a group returns the
+// temperatures of all of the devices in that group and a group and device
+// number returns the temperature of that device alone.
+func temperatures(ctx context.Context, sess *streamconn.Session, args []string) ([]TemperatureInfo, error) {
+	if len(args) == 0 {
+		all, err := protocol.GetTemperatures(ctx, sess)
+		if errors.Is(err, protocol.ErrUnsupported) {
+			// Firmware that predates lw supports reading each device
+			// individually.
+			temps, err := groupTemperatures(ctx, sess, protocol.KeypadGroup)
+			if err != nil {
+				return nil, err
+			}
+			probes, err := groupTemperatures(ctx, sess, protocol.TemperatureProbeGroup)
+			return append(temps, probes...), err
+		}
+		if err != nil {
+			return nil, err
+		}
+		temps := appendTemperatures(nil, protocol.KeypadGroup, all.Keypads[:])
+		return appendTemperatures(temps, protocol.TemperatureProbeGroup, all.Probes[:]), nil
+	}
+	group, err := protocol.ParseTemperatureGroup(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 1 {
+		device, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v number: %v: %w", group, args[1], err)
+		}
+		t, err := protocol.GetTemperature(ctx, sess, group, device)
+		if err != nil {
+			return nil, err
+		}
+		return []TemperatureInfo{{Group: group.String(), Device: device, Temperature: t}}, nil
+	}
+	return groupTemperatures(ctx, sess, group)
+}
+
+// groupTemperatures returns the temperatures of all of the devices in
+// group that report one.
+func groupTemperatures(ctx context.Context, sess *streamconn.Session, group protocol.TemperatureGroup) ([]TemperatureInfo, error) {
+	temps := []TemperatureInfo{}
+	for device := 1; device <= protocol.NumTemperatureDevices; device++ {
+		t, err := protocol.GetTemperature(ctx, sess, group, device)
+		if errors.Is(err, protocol.ErrNoTemperature) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		temps = append(temps, TemperatureInfo{Group: group.String(), Device: device, Temperature: t})
+	}
+	return temps, nil
+}
+
+func (m1 *M1xep) getTemperatures(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	temps, err := temperatures(ctx, sess, args.Args)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range temps {
+		fmt.Fprintf(args.Writer, "%v %v: %vF\n", t.Group, t.Device, t.Temperature)
+	}
+	return temps, nil
+}
@@ -0,0 +1,233 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+	"gopkg.in/yaml.v3"
+)
+
+type ThermostatConfig struct {
+	ThermostatNumber int `yaml:"thermostat"`
+}
+
+type Thermostat struct {
+	m1DeviceBase
+	devices.DeviceBase[ThermostatConfig]
+}
+
+func NewThermostat(_ devices.Options) *Thermostat {
+	return &Thermostat{
+		m1DeviceBase: m1DeviceBase{},
+	}
+}
+
+func (t *Thermostat) UnmarshalYAML(node *yaml.Node) error {
+	if err := node.Decode(&t.DeviceConfigCustom); err != nil {
+		return err
+	}
+	if tn := t.DeviceConfigCustom.ThermostatNumber; tn < 1 || tn > protocol.NumThermostats {
+		return fmt.Errorf("invalid thermostat number: %v", tn)
+	}
+	return nil
+}
+
+func (t *Thermostat) Operations() map[string]devices.Operation {
+	return map[string]devices.Operation{
+		"status":   t.Status,
+		"set-mode": t.SetMode,
+		"set-heat": t.setter(protocol.ThermostatHeatSetPointElement, parseTemperature),
+		"set-cool": t.setter(protocol.ThermostatCoolSetPointElement, parseTemperature),
+		"set-fan":  t.setter(protocol.ThermostatFanElement, parseFan),
+		"set-hold": t.setter(protocol.ThermostatHoldElement, parseHold),
+	}
+}
+
+func (t *Thermostat) OperationsHelp() map[string]string {
+	return map[string]string{
+		"status":   "display the thermostat's temperature, setpoints, mode, fan and hold state",
+		"set-mode": "set the thermostat's mode to one of off, heat, cool, auto or emergency-heat",
+		"set-heat": "set the heat setpoint, in degrees Fahrenheit",
+		"set-cool": "set the cool setpoint, in degrees Fahrenheit",
+		"set-fan":  "set the fan to auto or on",
+		"set-hold": "set the hold to on or off",
+	}
+}
+
+func (t *Thermostat) Conditions() map[string]devices.Condition {
+	return map[string]devices.Condition{
+		"mode-off":          t.modeCondition(protocol.ThermostatOff),
+		"mode-heat":         t.modeCondition(protocol.ThermostatHeat),
+		"mode-cool":         t.modeCondition(protocol.ThermostatCool),
+		"mode-auto":         t.modeCondition(protocol.ThermostatAuto),
+		"fan-on":            t.condition(func(td protocol.ThermostatData) bool { return td.FanOn }),
+		"hold":              t.condition(func(td protocol.ThermostatData) bool { return td.Hold }),
+		"temperature-above": t.TemperatureAbove,
+		"temperature-below": t.TemperatureBelow,
+	}
+}
+
+func (t *Thermostat) ConditionsHelp() map[string]string {
+	return map[string]string{
+		"mode-off":          "true if the thermostat is off",
+		"mode-heat":         "true if the thermostat is in heat mode",
+		"mode-cool":         "true if the thermostat is in cool mode",
+		"mode-auto":         "true if the thermostat is in auto mode",
+		"fan-on":            "true if the thermostat's fan is on rather than auto",
+		"hold":              "true if the thermostat's hold is on",
+		"temperature-above": "true if the current temperature is above the specified threshold, the temperature is returned",
+		"temperature-below": "true if the current temperature is below the specified threshold, the temperature is returned",
+	}
+}
+
+func parseTemperature(arg string) (int, error) {
+	v, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("invalid temperature: %v: %w", arg, err)
+	}
+	return v, nil
+}
+
+func parseFan(arg string) (int, error) {
+	switch arg {
+	case "auto":
+		return 0, nil
+	case "on":
+		return 1, nil
+	}
+	return 0, fmt.Errorf("invalid fan setting: %q, must be auto or on", arg)
+}
+
+func parseHold(arg string) (int, error) {
+	switch arg {
+	case "off":
+		return 0, nil
+	case "on":
+		return 1, nil
+	}
+	return 0, fmt.Errorf("invalid hold setting: %q, must be on or off", arg)
+}
+
+func (t *Thermostat) write(opts devices.OperationArgs, td protocol.ThermostatData) {
+	if opts.Writer != nil {
+		_, _ = opts.Writer.Write(fmt.Appendf(nil, "thermostat: %v, mode %v, hold %v, fan on %v, temperature %v, heat %v, cool %v, humidity %v",
+			td.Thermostat, td.Mode, td.Hold, td.FanOn, td.Temperature, td.HeatSetPoint, td.CoolSetPoint, td.Humidity))
+	}
+	if t.logger != nil {
+		t.logger.Info("thermostat-status", "thermostat", td.Thermostat, "mode", td.Mode, "hold", td.Hold, "fan-on", td.FanOn, "temperature", td.Temperature, "heat", td.HeatSetPoint, "cool", td.CoolSetPoint, "humidity", td.Humidity)
+	}
+}
+
+func (t *Thermostat) set(ctx context.Context, opts devices.OperationArgs, element protocol.ThermostatElement, value int) (any, error) {
+	tn := t.DeviceConfigCustom.ThermostatNumber
+	return t.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, opts devices.OperationArgs) (any, error) {
+		td, err := protocol.SetThermostat(ctx, sess, tn, element, value)
+		if err != nil {
+			return nil, err
+		}
+		t.write(opts, td)
+		return td, nil
+	}, opts)
+}
+
+func (t *Thermostat) setter(element protocol.ThermostatElement, parse func(string) (int, error)) devices.Operation {
+	return func(ctx context.Context, opts devices.OperationArgs) (any, error) {
+		if len(opts.Args) != 1 {
+			return nil, fmt.Errorf("exactly one argument is required")
+		}
+		v, err := parse(opts.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return t.set(ctx, opts, element, v)
+	}
+}
+
+func (t *Thermostat) SetMode(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	if len(opts.Args) != 1 {
+		return nil, fmt.Errorf("set-mode requires a mode")
+	}
+	mode, err := protocol.ParseThermostatMode(opts.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	return t.set(ctx, opts, protocol.ThermostatModeElement, int(mode))
+}
+
+func (t *Thermostat) status(ctx context.Context, opts devices.OperationArgs) (protocol.ThermostatData, error) {
+	ctx, sess, err := t.m1.session(ctx)
+	if err != nil {
+		return protocol.ThermostatData{}, err
+	}
+	defer sess.Release()
+	td, err := protocol.GetThermostat(ctx, sess, t.DeviceConfigCustom.ThermostatNumber)
+	if err != nil {
+		return protocol.ThermostatData{}, err
+	}
+	t.write(opts, td)
+	return td, nil
+}
+
+func (t *Thermostat) Status(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	return t.status(ctx, opts)
+}
+
+func (t *Thermostat) condition(pred func(protocol.ThermostatData) bool) devices.Condition {
+	return func(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+		td, err := t.status(ctx, opts)
+		if err != nil {
+			return nil, false, err
+		}
+		return nil, pred(td), nil
+	}
+}
+
+func (t *Thermostat) modeCondition(mode protocol.ThermostatMode) devices.Condition {
+	return t.condition(func(td protocol.ThermostatData) bool {
+		return td.Mode == mode
+	})
+}
+
+// temperature returns the current temperature and the threshold
+// specified as the condition's argument.
+func (t *Thermostat) temperature(ctx context.Context, opts devices.OperationArgs) (int, float64, error) {
+	if len(opts.Args) != 1 {
+		return 0, 0, fmt.Errorf("a temperature threshold is required")
+	}
+	threshold, err := parseThreshold(opts.Args[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	td, err := t.status(ctx, devices.OperationArgs{Writer: opts.Writer})
+	if err != nil {
+		return 0, 0, err
+	}
+	if td.Temperature == 0 {
+		return 0, 0, fmt.Errorf("thermostat %v: temperature is not available", td.Thermostat)
+	}
+	return td.Temperature, threshold, nil
+}
+
+func (t *Thermostat) TemperatureAbove(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	temp, threshold, err := t.temperature(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return temp, float64(temp) > threshold, nil
+}
+
+func (t *Thermostat) TemperatureBelow(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	temp, threshold, err := t.temperature(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return temp, float64(temp) < threshold, nil
+}
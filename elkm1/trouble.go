@@ -0,0 +1,105 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+type TroubleInfo struct {
+	Name string `json:"name"`
+	Zone int    `json:"zone,omitempty"`
+}
+
+func troubleInfo(ts protocol.TroubleStatus) []TroubleInfo {
+	troubles := []TroubleInfo{}
+	for _, t := range ts.Troubles() {
+		troubles = append(troubles, TroubleInfo{Name: t.Name, Zone: t.Zone})
+	}
+	return troubles
+}
+
+func (m1 *M1xep) getTrouble(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	ts, err := protocol.GetTroubleStatus(ctx, sess)
+	if err != nil {
+		return nil, err
+	}
+	troubles := ts.Troubles()
+	if len(troubles) == 0 {
+		fmt.Fprintf(args.Writer, "trouble: none\n")
+	}
+	for _, t := range troubles {
+		fmt.Fprintf(args.Writer, "trouble: %v\n", t)
+	}
+	return troubleInfo(ts), nil
+}
+
+// Conditions returns conditions on the panel's health, as reported by
+// its system trouble status.
+func (m1 *M1xep) Conditions() map[string]devices.Condition {
+	return map[string]devices.Condition{
+		"trouble": m1.troubleCondition(func(ts protocol.TroubleStatus) bool {
+			return len(ts.Troubles()) > 0
+		}),
+		"ac-fail": m1.troubleCondition(func(ts protocol.TroubleStatus) bool {
+			return ts.ACFail
+		}),
+		"low-battery": m1.troubleCondition(func(ts protocol.TroubleStatus) bool {
+			return ts.LowBatteryControl
+		}),
+		"transmitter-low-battery": m1.troubleCondition(func(ts protocol.TroubleStatus) bool {
+			return ts.TransmitterLowBatteryZone != 0
+		}),
+		"lost-transmitter": m1.troubleCondition(func(ts protocol.TroubleStatus) bool {
+			return ts.LostTransmitterZone != 0
+		}),
+		"box-tamper": m1.troubleCondition(func(ts protocol.TroubleStatus) bool {
+			return ts.BoxTamperZone != 0
+		}),
+		"fail-to-communicate": m1.troubleCondition(func(ts protocol.TroubleStatus) bool {
+			return ts.FailToCommunicate
+		}),
+		"telephone-fault": m1.troubleCondition(func(ts protocol.TroubleStatus) bool {
+			return ts.TelephoneFault
+		}),
+		"fire-trouble": m1.troubleCondition(func(ts protocol.TroubleStatus) bool {
+			return ts.FireZone != 0
+		}),
+	}
+}
+
+func (m1 *M1xep) ConditionsHelp() map[string]string {
+	return map[string]string{
+		"trouble":                 "true if there is any system trouble, the troubles are returned",
+		"ac-fail":                 "true if the panel has lost AC power",
+		"low-battery":             "true if the panel's battery is low",
+		"transmitter-low-battery": "true if a wireless transmitter's battery is low",
+		"lost-transmitter":        "true if a wireless transmitter has not been heard from",
+		"box-tamper":              "true if a box tamper zone is violated",
+		"fail-to-communicate":     "true if the panel failed to communicate with the central station",
+		"telephone-fault":         "true if there is a telephone line fault",
+		"fire-trouble":            "true if a fire zone is in trouble",
+	}
+}
+
+func (m1 *M1xep) troubleCondition(pred func(protocol.TroubleStatus) bool) devices.Condition {
+	return func(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+		ctx, sess, err := m1.session(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		defer sess.Release()
+		ts, err := protocol.GetTroubleStatus(ctx, sess)
+		if err != nil {
+			return nil, false, err
+		}
+		return troubleInfo(ts), pred(ts), nil
+	}
+}
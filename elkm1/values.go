@@ -0,0 +1,284 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+	"gopkg.in/yaml.v3"
+)
+
+// valueConditions returns the conditions common to counters and custom
+// values, value returns the current value and threshold returns the
+// current value along with the threshold argument parsed accordingly.
+func valueConditions(value func(context.Context, devices.OperationArgs) (int, error), threshold func(context.Context, devices.OperationArgs, string) (int, int, error)) map[string]devices.Condition {
+	compare := func(cmp func(v, threshold int) bool) devices.Condition {
+		return func(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+			if len(opts.Args) != 1 {
+				return nil, false, fmt.Errorf("a value to compare against is required")
+			}
+			v, t, err := threshold(ctx, opts, opts.Args[0])
+			if err != nil {
+				return nil, false, err
+			}
+			return v, cmp(v, t), nil
+		}
+	}
+	return map[string]devices.Condition{
+		"equal": compare(func(v, threshold int) bool { return v == threshold }),
+		"above": compare(func(v, threshold int) bool { return v > threshold }),
+		"below": compare(func(v, threshold int) bool { return v < threshold }),
+		"nonzero": func(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+			v, err := value(ctx, opts)
+			if err != nil {
+				return nil, false, err
+			}
+			return v, v != 0, nil
+		},
+	}
+}
+
+var valueConditionsHelp = map[string]string{
+	"equal":   "true if the value is equal to the specified value",
+	"above":   "true if the value is above the specified value",
+	"below":   "true if the value is below the specified value",
+	"nonzero": "true if the value is not zero, ie. when used as a flag",
+}
+
+type CounterConfig struct {
+	CounterNumber int `yaml:"counter"`
+}
+
+type Counter struct {
+	m1DeviceBase
+	devices.DeviceBase[CounterConfig]
+}
+
+func NewCounter(_ devices.Options) *Counter {
+	return &Counter{
+		m1DeviceBase: m1DeviceBase{},
+	}
+}
+
+func (c *Counter) UnmarshalYAML(node *yaml.Node) error {
+	if err := node.Decode(&c.DeviceConfigCustom); err != nil {
+		return err
+	}
+	if cn := c.DeviceConfigCustom.CounterNumber; cn < 1 || cn > protocol.NumCounters {
+		return fmt.Errorf("invalid counter number: %v", cn)
+	}
+	return nil
+}
+
+func (c *Counter) Operations() map[string]devices.Operation {
+	return map[string]devices.Operation{
+		"get": c.Get,
+		"set": c.Set,
+	}
+}
+
+func (c *Counter) OperationsHelp() map[string]string {
+	return map[string]string{
+		"get": "get the counter's value",
+		"set": fmt.Sprintf("set the counter to the specified value, 0 to %v", protocol.MaxValue),
+	}
+}
+
+func (c *Counter) Conditions() map[string]devices.Condition {
+	return valueConditions(c.value, c.threshold)
+}
+
+func (c *Counter) ConditionsHelp() map[string]string {
+	return valueConditionsHelp
+}
+
+type CounterInfo struct {
+	Counter int `json:"counter"`
+	Value   int `json:"value"`
+}
+
+func (c *Counter) run(ctx context.Context, opts devices.OperationArgs, op func(context.Context, *streamconn.Session, int) (int, error)) (int, error) {
+	cn := c.DeviceConfigCustom.CounterNumber
+	v, err := c.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, _ devices.OperationArgs) (any, error) {
+		return op(ctx, sess, cn)
+	}, opts)
+	if err != nil {
+		return 0, err
+	}
+	if opts.Writer != nil {
+		_, _ = opts.Writer.Write(fmt.Appendf(nil, "counter: %v, value %v", cn, v))
+	}
+	if c.logger != nil {
+		c.logger.Info("counter", "counter", cn, "value", v)
+	}
+	return v.(int), nil
+}
+
+func (c *Counter) value(ctx context.Context, opts devices.OperationArgs) (int, error) {
+	return c.run(ctx, opts, protocol.GetCounter)
+}
+
+func (c *Counter) threshold(ctx context.Context, opts devices.OperationArgs, arg string) (int, int, error) {
+	t, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value: %v: %w", arg, err)
+	}
+	v, err := c.value(ctx, opts)
+	return v, t, err
+}
+
+func (c *Counter) Get(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	v, err := c.value(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return CounterInfo{Counter: c.DeviceConfigCustom.CounterNumber, Value: v}, nil
+}
+
+func (c *Counter) Set(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	if len(opts.Args) != 1 {
+		return nil, fmt.Errorf("set requires a value")
+	}
+	value, err := strconv.Atoi(opts.Args[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid value: %v: %w", opts.Args[0], err)
+	}
+	v, err := c.run(ctx, opts, func(ctx context.Context, sess *streamconn.Session, cn int) (int, error) {
+		return protocol.SetCounter(ctx, sess, cn, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return CounterInfo{Counter: c.DeviceConfigCustom.CounterNumber, Value: v}, nil
+}
+
+type CustomValueConfig struct {
+	CustomValueNumber int `yaml:"custom_value"`
+}
+
+type CustomValue struct {
+	m1DeviceBase
+	devices.DeviceBase[CustomValueConfig]
+}
+
+func NewCustomValue(_ devices.Options) *CustomValue {
+	return &CustomValue{
+		m1DeviceBase: m1DeviceBase{},
+	}
+}
+
+func (c *CustomValue) UnmarshalYAML(node *yaml.Node) error {
+	if err := node.Decode(&c.DeviceConfigCustom); err != nil {
+		return err
+	}
+	if cn := c.DeviceConfigCustom.CustomValueNumber; cn < 1 || cn > protocol.NumCustomValues {
+		return fmt.Errorf("invalid custom value number: %v", cn)
+	}
+	return nil
+}
+
+func (c *CustomValue) Operations() map[string]devices.Operation {
+	return map[string]devices.Operation{
+		"get": c.Get,
+		"set": c.Set,
+	}
+}
+
+func (c *CustomValue) OperationsHelp() map[string]string {
+	return map[string]string{
+		"get": "get the custom value",
+		"set": "set the custom value, time of day values are specified as HH:MM",
+	}
+}
+
+func (c *CustomValue) Conditions() map[string]devices.Condition {
+	return valueConditions(c.value, c.threshold)
+}
+
+func (c *CustomValue) ConditionsHelp() map[string]string {
+	return valueConditionsHelp
+}
+
+type CustomValueInfo struct {
+	CustomValue int    `json:"custom_value"`
+	Format      string `json:"format"`
+	Value       string `json:"value"`
+}
+
+func (c *CustomValue) get(ctx context.Context, opts devices.OperationArgs) (protocol.CustomValue, error) {
+	cn := c.DeviceConfigCustom.CustomValueNumber
+	v, err := c.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, _ devices.OperationArgs) (any, error) {
+		return protocol.GetCustomValue(ctx, sess, cn)
+	}, opts)
+	if err != nil {
+		return protocol.CustomValue{}, err
+	}
+	cv := v.(protocol.CustomValue)
+	if opts.Writer != nil {
+		_, _ = opts.Writer.Write(fmt.Appendf(nil, "custom value: %v, value %v (%v)", cn, cv, cv.Format))
+	}
+	if c.logger != nil {
+		c.logger.Info("custom-value", "custom_value", cn, "value", cv.String(), "format", cv.Format.String())
+	}
+	return cv, nil
+}
+
+func (c *CustomValue) value(ctx context.Context, opts devices.OperationArgs) (int, error) {
+	cv, err := c.get(ctx, opts)
+	return cv.Value, err
+}
+
+// threshold returns the current value and parses arg using the format
+// of the custom value as configured in the M1, both are taken from the
+// same CR reply.
+func (c *CustomValue) threshold(ctx context.Context, opts devices.OperationArgs, arg string) (int, int, error) {
+	cv, err := c.get(ctx, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	t, err := protocol.ParseCustomValue(cv.Format, arg)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cv.Value, t, nil
+}
+
+func (c *CustomValue) Get(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	cv, err := c.get(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return CustomValueInfo{CustomValue: c.DeviceConfigCustom.CustomValueNumber, Format: cv.Format.String(), Value: cv.String()}, nil
+}
+
+func (c *CustomValue) Set(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	if len(opts.Args) != 1 {
+		return nil, fmt.Errorf("set requires a value")
+	}
+	cn := c.DeviceConfigCustom.CustomValueNumber
+	return c.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, _ devices.OperationArgs) (any, error) {
+		cv, err := protocol.GetCustomValue(ctx, sess, cn)
+		if err != nil {
+			return nil, err
+		}
+		v, err := protocol.ParseCustomValue(cv.Format, opts.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		if err := protocol.SetCustomValue(ctx, sess, cn, v); err != nil {
+			return nil, err
+		}
+		cv.Value = v
+		if c.logger != nil {
+			c.logger.Info("custom-value-set", "custom_value", cn, "value", cv.String(), "format", cv.Format.String())
+		}
+		return CustomValueInfo{CustomValue: cn, Format: cv.Format.String(), Value: cv.String()}, nil
+	}, opts)
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+func TestCustomValueConditions(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conns := acceptM1XEP(l)
+
+	request := strings.TrimSpace(string(protocol.Encode(&protocol.CustomValueRequest{Index: 3})))
+	reply := protocol.Encode(&protocol.CustomValueReply{Index: 3, Values: []protocol.CustomValue{
+		{Value: protocol.TimeOfDayValue(7, 30), Format: protocol.CustomTimeOfDay},
+	}})
+	var mu sync.Mutex
+	requests := 0
+	go func() {
+		for fc := range conns {
+			go func() {
+				for line := range fc.lines {
+					if line != request {
+						continue
+					}
+					mu.Lock()
+					requests++
+					mu.Unlock()
+					_, _ = fc.Write(reply)
+				}
+			}()
+		}
+	}()
+
+	m1 := newTestM1XEP(l, time.Minute)
+	defer m1.Close(context.Background())
+	cv := NewCustomValue(devices.Options{})
+	cv.DeviceConfigCustom.CustomValueNumber = 3
+	cv.m1 = m1
+
+	conditions := cv.Conditions()
+	for i, tc := range []struct {
+		condition, arg string
+		result         bool
+	}{
+		{"above", "07:00", true},
+		{"below", "07:00", false},
+		{"equal", "07:30", true},
+	} {
+		v, result, err := conditions[tc.condition](context.Background(), devices.OperationArgs{Args: []string{tc.arg}})
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", i, err)
+		}
+		if got, want := v, protocol.TimeOfDayValue(7, 30); got != want {
+			t.Errorf("%v: got %v, want %v", i, got, want)
+		}
+		if got, want := result, tc.result; got != want {
+			t.Errorf("%v: got %v, want %v", i, got, want)
+		}
+		// The value and the format used to parse the threshold are
+		// obtained from a single cr request.
+		mu.Lock()
+		if got, want := requests, i+1; got != want {
+			t.Errorf("%v: got %v, want %v", i, got, want)
+		}
+		mu.Unlock()
+	}
+
+	if _, _, err := conditions["above"](context.Background(), devices.OperationArgs{Args: []string{"7"}}); err == nil {
+		t.Errorf("expected an error for a threshold in the wrong format")
+	}
+}
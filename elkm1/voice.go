@@ -0,0 +1,57 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/automation/net/streamconn"
+	"github.com/cosnicolaou/elk/elkm1/protocol"
+)
+
+type SpeakInfo struct {
+	Number int    `json:"number"`
+	Name   string `json:"name"`
+}
+
+func (m1 *M1xep) speakWords(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	return speak(ctx, sess, args, "word", protocol.ParseWord, protocol.WordName, protocol.SpeakWord)
+}
+
+func (m1 *M1xep) speakPhrases(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs) (any, error) {
+	return speak(ctx, sess, args, "phrase", protocol.ParsePhrase, protocol.PhraseName, protocol.SpeakPhrase)
+}
+
+// speak speaks the sequence of words or phrases, specified by name or
+// number, in args. All of the arguments are validated before any are
+// spoken.
+func speak(ctx context.Context, sess *streamconn.Session, args devices.OperationArgs, what string,
+	parse func(string) (int, error),
+	name func(int) string,
+	say func(context.Context, *streamconn.Session, int) error) (any, error) {
+	if len(args.Args) == 0 {
+		return nil, fmt.Errorf("no %vs specified", what)
+	}
+	spoken := make([]SpeakInfo, 0, len(args.Args))
+	for _, arg := range args.Args {
+		n, err := parse(strings.ToLower(arg))
+		if err != nil {
+			return nil, err
+		}
+		spoken = append(spoken, SpeakInfo{Number: n, Name: name(n)})
+	}
+	names := make([]string, 0, len(spoken))
+	for _, s := range spoken {
+		if err := say(ctx, sess, s.Number); err != nil {
+			return nil, err
+		}
+		names = append(names, s.Name)
+	}
+	fmt.Fprintf(args.Writer, "speak: %v\n", strings.Join(names, " "))
+	return spoken, nil
+}
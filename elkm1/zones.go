@@ -8,14 +8,31 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/cosnicolaou/automation/devices"
+	"github.com/cosnicolaou/automation/net/streamconn"
 	"github.com/cosnicolaou/elk/elkm1/protocol"
 	"gopkg.in/yaml.v3"
 )
 
 type ZoneConfig struct {
 	ZoneNumber int `yaml:"zone"`
+	// AreaNumber is the area that the zone belongs to, the configured
+	// user code must be valid in that area to bypass the zone. It
+	// defaults to 1.
+	AreaNumber int `yaml:"area"`
+	// VoltageAbove and VoltageBelow are the thresholds, in volts, used
+	// by the voltage-above and voltage-below conditions for analog zones
+	// when no threshold=<value> argument is specified.
+	VoltageAbove *float64 `yaml:"voltage_above"`
+	VoltageBelow *float64 `yaml:"voltage_below"`
+	// TemperatureAbove and TemperatureBelow are the thresholds, in degrees
+	// Fahrenheit, used by the temperature-above and temperature-below
+	// conditions for zones 1 to 16 with a temperature probe when no
+	// threshold=<value> argument is specified.
+	TemperatureAbove *float64 `yaml:"temperature_above"`
+	TemperatureBelow *float64 `yaml:"temperature_below"`
 }
 
 type Zone struct {
@@ -27,9 +44,15 @@ func (z *Zone) UnmarshalYAML(node *yaml.Node) error {
 	if err := node.Decode(&z.DeviceConfigCustom); err != nil {
 		return err
 	}
-	if zn := z.DeviceConfigCustom.ZoneNumber; zn > protocol.NumZones {
+	if zn := z.DeviceConfigCustom.ZoneNumber; zn < 1 || zn > protocol.NumZones {
 		return fmt.Errorf("invalid zone number: %v", zn)
 	}
+	if z.DeviceConfigCustom.AreaNumber == 0 {
+		z.DeviceConfigCustom.AreaNumber = 1
+	}
+	if an := z.DeviceConfigCustom.AreaNumber; an < 1 || an > protocol.NumAreas {
+		return fmt.Errorf("invalid area number: %v", an)
+	}
 	return nil
 }
 
@@ -41,6 +64,13 @@ func (z *Zone) Conditions() map[string]devices.Condition {
 		"violated": z.Violated,
 		"trouble":  z.Trouble,
 		"bypassed": z.Bypassed,
+		"in-alarm": z.InAlarm,
+
+		"voltage-above": z.VoltageAbove,
+		"voltage-below": z.VoltageBelow,
+
+		"temperature-above": z.TemperatureAbove,
+		"temperature-below": z.TemperatureBelow,
 	}
 }
 
@@ -52,6 +82,35 @@ func (z *Zone) ConditionsHelp() map[string]string {
 		"violated": "true if the zone is in a violated state",
 		"trouble":  "true if the zone is in a trouble state",
 		"bypassed": "true if the zone is in a bypassed state",
+		"in-alarm": "true if the zone has caused an alarm that has not been acknowledged, the alarm type is returned",
+
+		"voltage-above": "true if the analog zone's voltage is above the configured, or specified, threshold: [zone] [threshold=<volts>]",
+		"voltage-below": "true if the analog zone's voltage is below the configured, or specified, threshold: [zone] [threshold=<volts>]",
+
+		"temperature-above": "true if the zone's temperature probe reads above the configured, or specified, threshold: [zone] [threshold=<degrees>]",
+		"temperature-below": "true if the zone's temperature probe reads below the configured, or specified, threshold: [zone] [threshold=<degrees>]",
+	}
+}
+
+func (z *Zone) Operations() map[string]devices.Operation {
+	return map[string]devices.Operation{
+		"bypass":   z.Bypass,
+		"unbypass": z.Unbypass,
+		"trigger":  z.Trigger,
+		"voltage":  z.Voltage,
+
+		"temperature": z.Temperature,
+	}
+}
+
+func (z *Zone) OperationsHelp() map[string]string {
+	return map[string]string{
+		"bypass":   "bypass the zone using the configured user code",
+		"unbypass": "unbypass the zone using the configured user code",
+		"trigger":  "momentarily violate the zone, as if it had been opened",
+		"voltage":  "read the voltage of an analog zone",
+
+		"temperature": "read the temperature of the zone's temperature probe",
 	}
 }
 
@@ -61,6 +120,23 @@ func NewZone(_ devices.Options) *Zone {
 	}
 }
 
+// zoneNumber returns the configured zone number or the zone number
+// specified as the first argument.
+func (z *Zone) zoneNumber(opts devices.OperationArgs) (int, error) {
+	zn := z.DeviceConfigCustom.ZoneNumber
+	if len(opts.Args) > 0 {
+		var err error
+		zn, err = strconv.Atoi(opts.Args[0])
+		if err != nil {
+			return 0, fmt.Errorf("invalid zone number: %v: %w", opts.Args[0], err)
+		}
+	}
+	if zn < 1 || zn > protocol.NumZones {
+		return 0, fmt.Errorf("invalid zone number: %v", zn)
+	}
+	return zn, nil
+}
+
 func (z *Zone) logical(ctx context.Context, opts devices.OperationArgs) (protocol.ZoneStatus, error) {
 	ctx, sess, err := z.m1.session(ctx)
 	if err != nil {
@@ -71,15 +147,9 @@ func (z *Zone) logical(ctx context.Context, opts devices.OperationArgs) (protoco
 	if err != nil {
 		return 0, err
 	}
-	zn := z.DeviceConfigCustom.ZoneNumber
-	if len(opts.Args) > 0 {
-		zn, err = strconv.Atoi(opts.Args[0])
-		if err != nil {
-			return 0, fmt.Errorf("invalid zone number: %v: %w", opts.Args[0], err)
-		}
-	}
-	if zn < 0 || zn > protocol.NumZones {
-		return 0, fmt.Errorf("invalid zone number: %v", zn)
+	zn, err := z.zoneNumber(opts)
+	if err != nil {
+		return 0, err
 	}
 	if opts.Writer != nil {
 		_, _ = opts.Writer.Write(fmt.Appendf(nil, "zone: %v, status %v", zn, status[zn-1]))
@@ -121,3 +191,250 @@ func (z *Zone) Bypassed(ctx context.Context, opts devices.OperationArgs) (any, b
 	}
 	return nil, status.Logical() == protocol.ZoneBypassed, nil
 }
+
+func (z *Zone) InAlarm(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	zn, err := z.zoneNumber(opts)
+	if err != nil {
+		return nil, false, err
+	}
+	ctx, sess, err := z.m1.session(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer sess.Release()
+	alarms, err := protocol.GetAlarmsByZone(ctx, sess)
+	if err != nil {
+		return nil, false, err
+	}
+	def := alarms[zn-1]
+	if opts.Writer != nil {
+		_, _ = opts.Writer.Write(fmt.Appendf(nil, "zone: %v, alarm %v", zn, def.Alarm()))
+	}
+	if z.logger != nil {
+		z.logger.Info("zone-alarm", "zone", zn, "alarm", def.Alarm(), "definition", def)
+	}
+	if def == protocol.DisabledZoneType {
+		return nil, false, nil
+	}
+	return def.Alarm().String(), true, nil
+}
+
+func (z *Zone) setBypass(ctx context.Context, opts devices.OperationArgs, bypass bool) (any, error) {
+	zn, err := z.zoneNumber(opts)
+	if err != nil {
+		return nil, err
+	}
+	code, err := z.m1.userCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	area := z.DeviceConfigCustom.AreaNumber
+	return z.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, _ devices.OperationArgs) (any, error) {
+		if err := protocol.SetZoneBypass(ctx, sess, zn, area, bypass, code); err != nil {
+			return nil, err
+		}
+		if z.logger != nil {
+			z.logger.Info("zone-bypass", "zone", zn, "area", area, "bypassed", bypass)
+		}
+		return BypassInfo{Zone: zn, Area: area, Bypassed: bypass}, nil
+	}, opts)
+}
+
+func (z *Zone) Bypass(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	return z.setBypass(ctx, opts, true)
+}
+
+func (z *Zone) Unbypass(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	return z.setBypass(ctx, opts, false)
+}
+
+func (z *Zone) Trigger(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	zn, err := z.zoneNumber(opts)
+	if err != nil {
+		return nil, err
+	}
+	return z.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, _ devices.OperationArgs) (any, error) {
+		if err := protocol.TriggerZone(ctx, sess, zn); err != nil {
+			return nil, err
+		}
+		if z.logger != nil {
+			z.logger.Info("zone-trigger", "zone", zn)
+		}
+		return ZoneInfo{Zone: zn}, nil
+	}, opts)
+}
+
+// checkZoneDefinition returns an error if the zone is not defined as def.
+func checkZoneDefinition(ctx context.Context, sess *streamconn.Session, zn int, def protocol.ZoneDef) error {
+	defs, err := protocol.GetZoneDefinitions(ctx, sess)
+	if err != nil {
+		return err
+	}
+	if got := defs[zn-1]; got != def {
+		return fmt.Errorf("zone %v is defined as %v, not %v", zn, got, def)
+	}
+	return nil
+}
+
+func (z *Zone) voltage(ctx context.Context, opts devices.OperationArgs) (int, float64, error) {
+	zn, err := z.zoneNumber(opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	volts, err := z.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, _ devices.OperationArgs) (any, error) {
+		if err := checkZoneDefinition(ctx, sess, zn, protocol.AnalogZone); err != nil {
+			return nil, err
+		}
+		return protocol.GetZoneVoltage(ctx, sess, zn)
+	}, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	if z.logger != nil {
+		z.logger.Info("zone-voltage", "zone", zn, "volts", volts)
+	}
+	return zn, volts.(float64), nil
+}
+
+type ZoneVoltage struct {
+	Zone  int     `json:"zone"`
+	Volts float64 `json:"volts"`
+}
+
+func (z *Zone) Voltage(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	zn, volts, err := z.voltage(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Writer != nil {
+		_, _ = opts.Writer.Write(fmt.Appendf(nil, "zone: %v, voltage %.1fV", zn, volts))
+	}
+	return ZoneVoltage{Zone: zn, Volts: volts}, nil
+}
+
+// parseThreshold parses a threshold used by a condition.
+func parseThreshold(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid threshold: %v: %w", s, err)
+	}
+	return v, nil
+}
+
+// zoneThreshold returns the threshold specified as a threshold=<value>
+// argument, or the configured threshold if none is specified, along with
+// the remaining arguments, leaving the first argument to always be the
+// zone number. It is an error for neither threshold to be set.
+func zoneThreshold(opts devices.OperationArgs, configured *float64, name string) (devices.OperationArgs, float64, error) {
+	var args []string
+	threshold := configured
+	for _, a := range opts.Args {
+		v, ok := strings.CutPrefix(a, "threshold=")
+		if !ok {
+			args = append(args, a)
+			continue
+		}
+		t, err := parseThreshold(v)
+		if err != nil {
+			return opts, 0, err
+		}
+		threshold = &t
+	}
+	if threshold == nil {
+		return opts, 0, fmt.Errorf("no threshold specified as an argument or configured as %v", name)
+	}
+	opts.Args = args
+	return opts, *threshold, nil
+}
+
+// VoltageAbove is true if the zone's voltage is above the threshold
+// specified as a threshold=<value> argument or configured as voltage_above.
+func (z *Zone) VoltageAbove(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	opts, threshold, err := zoneThreshold(opts, z.DeviceConfigCustom.VoltageAbove, "voltage_above")
+	if err != nil {
+		return nil, false, err
+	}
+	_, volts, err := z.voltage(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return volts, volts > threshold, nil
+}
+
+// VoltageBelow is true if the zone's voltage is below the threshold
+// specified as a threshold=<value> argument or configured as voltage_below.
+func (z *Zone) VoltageBelow(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	opts, threshold, err := zoneThreshold(opts, z.DeviceConfigCustom.VoltageBelow, "voltage_below")
+	if err != nil {
+		return nil, false, err
+	}
+	_, volts, err := z.voltage(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return volts, volts < threshold, nil
+}
+
+func (z *Zone) temperature(ctx context.Context, opts devices.OperationArgs) (int, int, error) {
+	zn, err := z.zoneNumber(opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	temp, err := z.m1.runOperation(ctx, func(ctx context.Context, sess *streamconn.Session, _ devices.OperationArgs) (any, error) {
+		if err := checkZoneDefinition(ctx, sess, zn, protocol.Temperature); err != nil {
+			return nil, err
+		}
+		return protocol.GetTemperature(ctx, sess, protocol.TemperatureProbeGroup, zn)
+	}, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	if z.logger != nil {
+		z.logger.Info("zone-temperature", "zone", zn, "temperature", temp)
+	}
+	return zn, temp.(int), nil
+}
+
+type ZoneTemperature struct {
+	Zone        int `json:"zone"`
+	Temperature int `json:"temperature"`
+}
+
+func (z *Zone) Temperature(ctx context.Context, opts devices.OperationArgs) (any, error) {
+	zn, temp, err := z.temperature(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Writer != nil {
+		_, _ = opts.Writer.Write(fmt.Appendf(nil, "zone: %v, temperature %vF", zn, temp))
+	}
+	return ZoneTemperature{Zone: zn, Temperature: temp}, nil
+}
+
+// TemperatureAbove is true if the zone's temperature is above the threshold
+// specified as a threshold=<value> argument or configured as temperature_above.
+func (z *Zone) TemperatureAbove(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	opts, threshold, err := zoneThreshold(opts, z.DeviceConfigCustom.TemperatureAbove, "temperature_above")
+	if err != nil {
+		return nil, false, err
+	}
+	_, temp, err := z.temperature(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return temp, float64(temp) > threshold, nil
+}
+
+// TemperatureBelow is true if the zone's temperature is below the threshold
+// specified as a threshold=<value> argument or configured as temperature_below.
+func (z *Zone) TemperatureBelow(ctx context.Context, opts devices.OperationArgs) (any, bool, error) {
+	opts, threshold, err := zoneThreshold(opts, z.DeviceConfigCustom.TemperatureBelow, "temperature_below")
+	if err != nil {
+		return nil, false, err
+	}
+	_, temp, err := z.temperature(ctx, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return temp, float64(temp) < threshold, nil
+}
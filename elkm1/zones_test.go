@@ -0,0 +1,75 @@
+// Copyright 2024 cloudeng llc. All rights reserved.
+// Use of this source code is governed by the Apache-2.0
+// license that can be found in the LICENSE file.
+
+package elkm1
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/cosnicolaou/automation/devices"
+	"gopkg.in/yaml.v3"
+)
+
+func TestZoneThreshold(t *testing.T) {
+	configured := 2.5
+	for i, tc := range []struct {
+		args       []string
+		configured *float64
+		rest       []string
+		threshold  float64
+	}{
+		{nil, &configured, nil, 2.5},
+		{[]string{"3"}, &configured, []string{"3"}, 2.5},
+		{[]string{"threshold=1.5"}, &configured, nil, 1.5},
+		{[]string{"3", "threshold=1.5"}, nil, []string{"3"}, 1.5},
+		{[]string{"threshold=0"}, nil, nil, 0},
+	} {
+		opts, threshold, err := zoneThreshold(devices.OperationArgs{Args: tc.args}, tc.configured, "voltage_above")
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", i, err)
+			continue
+		}
+		if got, want := opts.Args, tc.rest; !slices.Equal(got, want) {
+			t.Errorf("%v: got %v, want %v", i, got, want)
+		}
+		if got, want := threshold, tc.threshold; got != want {
+			t.Errorf("%v: got %v, want %v", i, got, want)
+		}
+	}
+
+	for _, args := range [][]string{nil, {"3"}, {"threshold=x"}} {
+		if _, _, err := zoneThreshold(devices.OperationArgs{Args: args}, nil, "voltage_above"); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+func TestZoneConfig(t *testing.T) {
+	var z Zone
+	if err := yaml.Unmarshal([]byte("zone: 3\nvoltage_below: 0\n"), &z); err != nil {
+		t.Fatal(err)
+	}
+	if vb := z.DeviceConfigCustom.VoltageBelow; vb == nil || *vb != 0 {
+		t.Errorf("got %v, want 0", vb)
+	}
+	if va := z.DeviceConfigCustom.VoltageAbove; va != nil {
+		t.Errorf("got %v, want nil", *va)
+	}
+	if got, want := z.DeviceConfigCustom.AreaNumber, 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if err := yaml.Unmarshal([]byte("zone: 3\narea: 4\n"), &z); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := z.DeviceConfigCustom.AreaNumber, 4; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, cfg := range []string{"zone: 0\n", "voltage_above: 1\n", "zone: 209\n", "zone: 1\narea: 9\n"} {
+		var z Zone
+		if err := yaml.Unmarshal([]byte(cfg), &z); err == nil {
+			t.Errorf("%q: expected an error", cfg)
+		}
+	}
+}